@@ -3,8 +3,10 @@ module github.com/mark-rushakoff/jty
 go 1.12
 
 require (
-	github.com/google/go-jsonnet v0.14.0
+	github.com/google/go-jsonnet v0.17.0
+	github.com/santhosh-tekuri/jsonschema v1.2.4
 	github.com/spf13/afero v1.2.2
 	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v2 v2.4.0
 	gopkg.in/yaml.v3 v3.0.0-20191120175047-4206685974f2
 )
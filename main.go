@@ -11,10 +11,30 @@ import (
 )
 
 func main() {
+	// Subcommands are only recognized as the first argument.
+	// To process an input file with the same name as a subcommand, use e.g. ./serve.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			importYAML(os.Args[2:])
+			return
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "test":
+			test(os.Args[2:])
+			return
+		}
+	}
+
 	fs := pflag.NewFlagSet("jty", pflag.ExitOnError)
 	fs.Usage = func() {
 		exe := filepath.Base(os.Args[0])
 		fmt.Fprintf(os.Stderr, "USAGE: %s [opts] [[INPUT_JSONNET OUTPUT_YAML]...]:\n", exe)
+		fmt.Fprintf(os.Stderr, "       %s --out TEMPLATE [opts] [INPUT_JSONNET...]\n", exe)
+		fmt.Fprintf(os.Stderr, "       %s import [opts] INPUT_YAML OUTPUT_JSONNET\n", exe)
+		fmt.Fprintf(os.Stderr, "       %s serve [opts]\n", exe)
+		fmt.Fprintf(os.Stderr, "       %s test [opts] [PATH...]\n\n", exe)
 		fmt.Fprintln(os.Stderr, fs.FlagUsages())
 		fmt.Fprintf(os.Stderr, `ENVIRONMENT VARIABLES
 
@@ -35,6 +55,12 @@ Evaluate in.jsonnet and save the resulting YAML as out.yaml:
 Evaluate multiple .jsonnet files and save the resulting YAML in specific locations:
     %[1]s in1.jsonnet out/1.yaml conf.jsonnet conf.yaml
 
+Evaluate in.jsonnet and print the resulting YAML to stdout:
+    %[1]s in.jsonnet -
+
+Evaluate app.jsonnet once per environment, saving app.dev.yml and app.prod.yml:
+    %[1]s --matrix env=dev,prod --out '{stem}.{env}.yml' app.jsonnet
+
 Evaluate each .jsonnet file under the current directory,
 and save the .yml file adjacent to the .jsonnet file:
     find . -name '*.jsonnet' \
@@ -75,6 +101,143 @@ and for each file foo.jsonnet save a relative yml/foo.yml file
 		FS: afero.NewOsFs(),
 	}
 	if err := c.Run(&flags); err != nil {
+		if !flags.Quiet {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		os.Exit(1)
+	}
+}
+
+func importYAML(args []string) {
+	fs := pflag.NewFlagSet("jty import", pflag.ExitOnError)
+	fs.Usage = func() {
+		exe := filepath.Base(os.Args[0])
+		fmt.Fprintf(os.Stderr, "USAGE: %s import [opts] INPUT_YAML OUTPUT_JSONNET:\n", exe)
+		fmt.Fprintln(os.Stderr, fs.FlagUsages())
+		fmt.Fprintf(os.Stderr, `Convert a YAML stream to Jsonnet that evaluates to an array of its documents,
+so that running jty on the result reproduces the YAML.
+Keys keep their original order, and multi-line strings become text blocks.
+Use - as OUTPUT_JSONNET to print the Jsonnet to stdout.
+
+Example:
+    %[1]s import --check deploy.yaml deploy.jsonnet
+`, exe)
+	}
+	var flags jty.ImportFlags
+	flags.AddToFlagSet(fs)
+	if err := fs.Parse(args); err != nil {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if flags.HelpRequested {
+		fs.Usage()
+		os.Exit(0)
+	}
+	flags.Args = fs.Args()
+
+	c := &jty.Command{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+
+		FS: afero.NewOsFs(),
+	}
+	if err := c.Import(&flags); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func serve(args []string) {
+	fs := pflag.NewFlagSet("jty serve", pflag.ExitOnError)
+	fs.Usage = func() {
+		exe := filepath.Base(os.Args[0])
+		fmt.Fprintf(os.Stderr, "USAGE: %s serve [opts]:\n", exe)
+		fmt.Fprintln(os.Stderr, fs.FlagUsages())
+		fmt.Fprintf(os.Stderr, `Serve JSON-RPC 1.0 requests to render Jsonnet to YAML, keeping imported files cached
+until they change on disk. Without --socket, a single client is served on stdin and stdout.
+
+The only method is Jty.Render, which takes an object like {"Path": "in.jsonnet"}
+or {"Path": "virtual.jsonnet", "Code": "[{a: 1}]"} and returns {"YAML": "..."}
+or {"Error": {"Stage": "evaluate", "Message": "..."}}.
+
+Example request:
+    {"method": "Jty.Render", "params": [{"Path": "in.jsonnet"}], "id": 1}
+
+JSONNET_PATH is handled the same way as when processing files.
+`)
+	}
+	var flags jty.ServeFlags
+	flags.AddToFlagSet(fs)
+	if err := fs.Parse(args); err != nil {
+		fs.Usage()
+		os.Exit(1)
+	}
+	flags.FinishParse(os.Getenv("JSONNET_PATH"))
+
+	if flags.HelpRequested {
+		fs.Usage()
+		os.Exit(0)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	c := &jty.Command{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+
+		FS: afero.NewOsFs(),
+	}
+	if err := c.Serve(&flags); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func test(args []string) {
+	fs := pflag.NewFlagSet("jty test", pflag.ExitOnError)
+	fs.Usage = func() {
+		exe := filepath.Base(os.Args[0])
+		fmt.Fprintf(os.Stderr, "USAGE: %s test [opts] [PATH...]:\n", exe)
+		fmt.Fprintln(os.Stderr, fs.FlagUsages())
+		fmt.Fprintf(os.Stderr, `Evaluate Jsonnet test files in a single VM and report the results as TAP or JUnit XML.
+Each PATH is a test file, or a directory searched recursively for *_test.jsonnet files;
+the default is the current directory.
+
+A test fails if evaluating it fails, for example because of a failed assert.
+A test that evaluates to an object like {pass: false, message: 'why'} fails too,
+and a test that evaluates to an object of such objects reports each field as a separate test case.
+
+Example:
+    %[1]s test --format junit lib/ > report.xml
+`, exe)
+	}
+	var flags jty.TestFlags
+	flags.AddToFlagSet(fs)
+	if err := fs.Parse(args); err != nil {
+		fs.Usage()
+		os.Exit(1)
+	}
+	flags.FinishParse(os.Getenv("JSONNET_PATH"))
+
+	if flags.HelpRequested {
+		fs.Usage()
+		os.Exit(0)
+	}
+	flags.Args = fs.Args()
+
+	c := &jty.Command{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+
+		FS: afero.NewOsFs(),
+	}
+	if err := c.Test(&flags); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
@@ -4,8 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"runtime"
+	"strings"
+	"text/template"
 
 	jsonnet "github.com/google/go-jsonnet"
 	"github.com/spf13/afero"
@@ -15,6 +20,13 @@ var (
 	ErrOddInputFiles      = errors.New("odd number of file arguments received; must be given in pairs")
 	ErrOddInputFilesStdin = errors.New(ErrOddInputFiles.Error() + " (ensure final item has terminating newline or NUL)")
 	ErrNoInputFiles       = errors.New("at least one input-output pair must be given")
+	ErrVerboseAndQuiet    = errors.New("--verbose and --quiet are mutually exclusive")
+	ErrZeroWithJSONL      = errors.New("--zero cannot be used with --stdin-format=jsonl")
+	ErrStdinInputAndPairs = errors.New("cannot read Jsonnet from stdin (input path -) when reading pairs from stdin")
+	ErrEmptyCode          = errors.New("empty Jsonnet code given as input")
+	ErrImportArgs         = errors.New("import requires exactly two arguments: input YAML and output Jsonnet")
+	ErrFmtAndFmtCheck     = errors.New("--fmt and --fmt-check are mutually exclusive")
+	ErrFmtImportsAlone    = errors.New("--fmt-imports requires --fmt or --fmt-check")
 
 	ErrEncounteredErrors = errors.New("encountered errors during processing; failing")
 )
@@ -25,10 +37,36 @@ type Command struct {
 	Stdout, Stderr io.Writer
 
 	FS afero.Fs
+
+	// Native functions registered on every VM the Command creates,
+	// after the built-in library if that is enabled,
+	// so these take precedence over built-in functions of the same name.
+	NativeFunctions []*jsonnet.NativeFunction
 }
 
 // Run inputs all the CLI-specified files to a new Processor.
 func (c *Command) Run(f *Flags) error {
+	if f.Verbose && f.Quiet {
+		return ErrVerboseAndQuiet
+	}
+
+	if f.Fmt && f.FmtCheck {
+		return ErrFmtAndFmtCheck
+	}
+	if f.FmtImports && !f.Fmt && !f.FmtCheck {
+		return ErrFmtImportsAlone
+	}
+
+	if f.Out == "" && (len(f.Matrix) > 0 || len(f.MatrixTLA) > 0) {
+		return ErrMatrixWithoutOut
+	}
+	if f.Out != "" && f.FromStdin {
+		return ErrOutWithStdin
+	}
+	if f.Out != "" && f.Exec {
+		return ErrOutWithExec
+	}
+
 	if f.FromStdin {
 		if len(f.Args) > 0 {
 			panic("error here")
@@ -37,46 +75,310 @@ func (c *Command) Run(f *Flags) error {
 		if len(f.Args) == 0 {
 			return ErrNoInputFiles
 		}
-		if len(f.Args)%2 != 0 {
+		// With --out, the arguments are only inputs.
+		if f.Out == "" && len(f.Args)%2 != 0 {
 			return ErrOddInputFiles
 		}
 	}
 
-	vm := jsonnet.MakeVM()
+	var jobs []Job
+	if f.FromStdin {
+		var err error
+		switch f.StdinFormat {
+		case "", StdinFormatPairs:
+			jobs, err = c.readStdinPairs(f)
+		case StdinFormatJSONL:
+			if f.Zero {
+				return ErrZeroWithJSONL
+			}
+			jobs, err = c.readStdinJSONL()
+		default:
+			return fmt.Errorf("unknown --stdin-format %q", f.StdinFormat)
+		}
+		if err != nil {
+			return err
+		}
+	} else if f.Out != "" {
+		var err error
+		jobs, err = outJobs(f)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Iterate through command line arguments.
+		for i := 0; i < len(f.Args); i += 2 {
+			jobs = append(jobs, Job{InPath: f.Args[i], OutPath: f.Args[i+1]})
+		}
+	}
+
+	if err := c.resolveCode(f, jobs); err != nil {
+		return err
+	}
+
+	if err := checkFormat(f.Format); err != nil {
+		return fmt.Errorf("invalid --format: %v", err)
+	}
+	for i := range jobs {
+		if jobs[i].Format == "" {
+			jobs[i].Format = f.Format
+		}
+	}
+
+	switch f.YAML11Lint {
+	case "", YAML11LintWarn, YAML11LintFail:
+	default:
+		return fmt.Errorf("invalid --yaml11-lint %q: must be %q or %q", f.YAML11Lint, YAML11LintWarn, YAML11LintFail)
+	}
+
+	if f.Lint && f.LintFailOn != "" {
+		if err := checkLintSeverity(f.LintFailOn); err != nil {
+			return fmt.Errorf("invalid --lint-fail-on: %v", err)
+		}
+	}
+
+	var mode os.FileMode
+	if f.Mode != "" {
+		var err error
+		mode, err = parseMode(f.Mode)
+		if err != nil {
+			return fmt.Errorf("invalid --mode: %v", err)
+		}
+	}
+
+	var header *template.Template
+	if f.Header != "" {
+		var err error
+		header, err = ParseHeader(f.Header)
+		if err != nil {
+			return fmt.Errorf("invalid header template: %v", err)
+		}
+		// Catch execution errors, such as unknown fields, before touching any output file.
+		if err := header.Execute(ioutil.Discard, HeaderData{}); err != nil {
+			return fmt.Errorf("invalid header template: %v", err)
+		}
+	}
+
+	schemas, err := c.loadSchemas(f)
+	if err != nil {
+		return err
+	}
+
+	// Validate every pair before touching any output file.
+	if err := checkConflicts(c.FS, jobs); err != nil {
+		return err
+	}
+
 	// For now, always set a FileImporter.
 	// Perhaps a custom Importer could be injected if that proves necessary for tests.
-	vm.Importer(&jsonnet.FileImporter{
+	// The same importer is shared by every VM so that imported files are only read once.
+	var fileImporter jsonnet.Importer = &jsonnet.FileImporter{
 		JPaths: f.JPaths,
-	})
+	}
+	var roots []string
+	if len(f.ImportRoots) > 0 {
+		roots = importRoots(f.ImportRoots, f.JPaths)
+		fileImporter = newSandboxImporter(fileImporter, roots)
+	}
+	if err := checkImportedOutputs(c.FS, fileImporter, jobs); err != nil {
+		return err
+	}
+	var jsonnetFormatter *JsonnetFormatter
+	var fmtImporter *jsonnetFmtImporter
+	if f.Fmt || f.FmtCheck {
+		jsonnetFormatter = NewJsonnetFormatter(f.Fmt)
+		if f.FmtImports {
+			fmtImporter = newJsonnetFmtImporter(fileImporter, jsonnetFormatter, f.JPaths)
+			fileImporter = fmtImporter
+		}
+	}
+	importer := newOutputGuardImporter(fileImporter, c.FS, jobs)
+	natives := c.natives(f.Natives, roots)
+	newVM := func() *jsonnet.VM {
+		vm := jsonnet.MakeVM()
+		vm.Importer(importer)
+		for _, nf := range natives {
+			vm.NativeFunction(nf)
+		}
+		return vm
+	}
+
+	logDest := c.Stderr
+	if f.Quiet {
+		logDest = ioutil.Discard
+	}
 
-	p := NewProcessor(vm, runtime.GOMAXPROCS(-1), c.FS, c.Stderr)
+	p := NewProcessor(newVM(), runtime.GOMAXPROCS(-1), c.FS, logDest)
+	p.NewVM = newVM
+	p.Stdout = c.Stdout
+	p.LabelStdout = countStdout(jobs) > 1
 	if f.DryRun {
 		p.DryRunDest = c.Stdout
 	}
+	p.Verbose = f.Verbose
+	p.Mark = f.Mark
+	p.Header = header
+	p.Schemas = schemas
+	p.KubeSort = f.KubeSort
+	p.KubeSplit = f.KubeSplit
+	p.Mode = mode
+	p.KeepMode = f.KeepMode
+	p.YAML11Lint = f.YAML11Lint
+	p.YAMLComments = f.YAMLComments
+	p.JsonnetFormatter = jsonnetFormatter
+	p.Lint = f.Lint
+	p.LintFailOn = f.LintFailOn
+	p.RecordResults = f.JUnit != "" && !f.DryRun
+	if fmtImporter != nil {
+		fmtImporter.p = p
+	}
 
-	if f.FromStdin {
-		if err := c.processFromStdin(f, p); err != nil {
-			p.Close()
+	for _, j := range jobs {
+		p.ProcessJob(j)
+	}
+
+	p.Close()
+
+	if p.RecordResults {
+		if err := c.writeJUnitReport(p, f.JUnit); err != nil {
 			return err
 		}
-	} else {
-		// Iterate through command line arguments.
-		for i := 0; i < len(f.Args); i += 2 {
-			p.Process(f.Args[i], f.Args[i+1])
+	}
+
+	if p.Failed() {
+		return ErrEncounteredErrors
+	}
+
+	// Only prune after every pair succeeded,
+	// so that an incomplete run never deletes anything.
+	for _, dir := range f.Prune {
+		if err := p.Prune(dir); err != nil {
+			return err
 		}
 	}
 
-	p.Close()
+	return nil
+}
 
-	// Don't need to take lock, as we have finished all goroutines which may access the field.
-	if p.didLogError {
-		return ErrEncounteredErrors
+// countStdout returns the number of jobs that write to stdout.
+func countStdout(jobs []Job) int {
+	n := 0
+	for _, j := range jobs {
+		if j.OutPath == StdoutPath {
+			n++
+		}
+	}
+	return n
+}
+
+// writeJUnitReport writes the JUnit XML report of p's results to path.
+func (c *Command) writeJUnitReport(p *Processor, path string) error {
+	var buf bytes.Buffer
+	if err := p.WriteJUnit(&buf); err != nil {
+		return fmt.Errorf("failed to build JUnit report: %v", err)
+	}
+	if err := afero.WriteFile(c.FS, path, buf.Bytes(), 0666); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %v", err)
+	}
+	return nil
+}
+
+// natives returns the native functions to register on each VM:
+// the built-in library if builtin is set, followed by c.NativeFunctions.
+// If roots is not empty, the built-in library only reads files within roots.
+func (c *Command) natives(builtin bool, roots []string) []*jsonnet.NativeFunction {
+	if !builtin {
+		return c.NativeFunctions
+	}
+	lib := NativeLibrary(c.FS)
+	if len(roots) > 0 {
+		lib = SandboxedNativeLibrary(c.FS, roots)
+	}
+	return append(lib, c.NativeFunctions...)
+}
+
+// Filenames used in error messages for Jsonnet that doesn't come from a file.
+const (
+	cmdlineFilename = "<cmdline>"
+	stdinFilename   = "<stdin>"
+)
+
+// resolveCode sets the Code of each job whose input is not a file:
+// every input when f.Exec is set, or inputs of StdinPath otherwise.
+func (c *Command) resolveCode(f *Flags, jobs []Job) error {
+	var stdin *string
+	for i := range jobs {
+		j := &jobs[i]
+		switch {
+		case f.Exec:
+			j.Code = j.InPath
+			j.InPath = cmdlineFilename
+		case j.InPath == StdinPath:
+			if f.FromStdin {
+				return ErrStdinInputAndPairs
+			}
+			if stdin == nil {
+				// Read stdin once, no matter how many jobs refer to it.
+				b, err := ioutil.ReadAll(c.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read stdin: %v", err)
+				}
+				s := string(b)
+				stdin = &s
+			}
+			j.Code = *stdin
+			j.InPath = stdinFilename
+		default:
+			continue
+		}
+
+		if j.Code == "" {
+			return ErrEmptyCode
+		}
 	}
 
 	return nil
 }
 
-func (c *Command) processFromStdin(f *Flags, p *Processor) error {
+// loadSchemas returns the Schemas specified in f, or nil if there are none.
+func (c *Command) loadSchemas(f *Flags) (*Schemas, error) {
+	if len(f.Schemas) == 0 && len(f.KindSchemas) == 0 {
+		return nil, nil
+	}
+
+	s := NewSchemas(c.FS)
+	for _, v := range f.Schemas {
+		glob, path, err := splitAssignment("--schema", v)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.AddGlob(glob, path); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range f.KindSchemas {
+		kind, path, err := splitAssignment("--kind-schema", v)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.AddKind(kind, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// splitAssignment splits a KEY=VALUE flag value.
+func splitAssignment(flagName, v string) (key, value string, err error) {
+	i := strings.IndexByte(v, '=')
+	if i <= 0 || i == len(v)-1 {
+		return "", "", fmt.Errorf("invalid %s value %q: must be formatted as KEY=VALUE", flagName, v)
+	}
+	return v[:i], v[i+1:], nil
+}
+
+// readStdinPairs reads all the input-output pairs from c.Stdin.
+func (c *Command) readStdinPairs(f *Flags) ([]Job, error) {
 	s := bufio.NewScanner(c.Stdin)
 
 	if f.Zero {
@@ -85,24 +387,23 @@ func (c *Command) processFromStdin(f *Flags, p *Processor) error {
 		s.Split(splitLF)
 	}
 
-	processed := false
+	var jobs []Job
 	for s.Scan() {
 		inPath := s.Text()
 
 		if !s.Scan() {
-			return ErrOddInputFilesStdin
+			return nil, ErrOddInputFilesStdin
 		}
 		outPath := s.Text()
 
-		p.Process(inPath, outPath)
-		processed = true
+		jobs = append(jobs, Job{InPath: inPath, OutPath: outPath})
 	}
 
-	if !processed {
-		return ErrNoInputFiles
+	if len(jobs) == 0 {
+		return nil, ErrNoInputFiles
 	}
 
-	return nil
+	return jobs, nil
 }
 
 func splitNul(data []byte, atEOF bool) (advance int, token []byte, err error) {
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -328,3 +329,286 @@ up: true
 		t.Fatalf("expected file content of out.yml to be %q; got %q", expYAML, got)
 	}
 }
+
+func TestCommand_Verbose(t *testing.T) {
+	tc := NewTestCommand("")
+	JYOneTwo.WriteJ(t, tc.FS, "in1.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args:    []string{"in1.jsonnet", "out1.yml"},
+		Verbose: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	JYOneTwo.ExpectY(t, tc.FS, "out1.yml")
+
+	want := "wrote out1.yml from in1.jsonnet"
+	if !strings.Contains(tc.Stderr.String(), want) {
+		t.Fatalf("expected stderr to contain %q but it didn't: %q", want, tc.Stderr.String())
+	}
+}
+
+func TestCommand_Quiet(t *testing.T) {
+	tc := NewTestCommand("")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		// Input file that doesn't exist.
+		Args:  []string{"1.jsonnet", "1.yml"},
+		Quiet: true,
+	}); err != jty.ErrEncounteredErrors {
+		t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+	}
+
+	if tc.Stdout.String() != "" {
+		t.Fatalf("expected no standard output, got %q", tc.Stdout.String())
+	}
+	if tc.Stderr.String() != "" {
+		t.Fatalf("expected no standard error, got %q", tc.Stderr.String())
+	}
+}
+
+func TestCommand_VerboseAndQuiet(t *testing.T) {
+	tc := NewTestCommand("")
+
+	err := tc.Cmd.Run(&jty.Flags{
+		Args:    []string{"in1.jsonnet", "out1.yml"},
+		Verbose: true,
+		Quiet:   true,
+	})
+	if err != jty.ErrVerboseAndQuiet {
+		t.Fatalf("expected ErrVerboseAndQuiet, got %v", err)
+	}
+}
+
+func TestCommand_Conflicts(t *testing.T) {
+	for name, tt := range map[string]struct {
+		args []string
+		want string
+	}{
+		"duplicate output": {
+			args: []string{"in1.jsonnet", "out.yml", "in2.jsonnet", "./out.yml"},
+			want: "output ./out.yml of pair 2 is also the output of pair 1",
+		},
+		"output is own input": {
+			args: []string{"in1.jsonnet", "in1.jsonnet"},
+			want: "output in1.jsonnet of pair 1 is the input of pair 1",
+		},
+		"output is other input": {
+			args: []string{"in1.jsonnet", "out1.yml", "in2.jsonnet", "dir/../in1.jsonnet"},
+			want: "output dir/../in1.jsonnet of pair 2 is the input of pair 1",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc := NewTestCommand("")
+			JYOneTwo.WriteJ(t, tc.FS, "in1.jsonnet")
+			JYSeq.WriteJ(t, tc.FS, "in2.jsonnet")
+
+			err := tc.Cmd.Run(&jty.Flags{Args: tt.args})
+			cErr, ok := err.(*jty.ConflictError)
+			if !ok {
+				t.Fatalf("expected *ConflictError, got %v", err)
+			}
+			if len(cErr.Conflicts) != 1 || cErr.Conflicts[0] != tt.want {
+				t.Fatalf("expected conflicts to be [%q], got %q", tt.want, cErr.Conflicts)
+			}
+
+			// Nothing should have been written.
+			if _, err := tc.FS.Stat("out1.yml"); err == nil {
+				t.Fatal("expected out1.yml not to be written")
+			}
+		})
+	}
+}
+
+func TestCommand_Conflicts_Symlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jty-conflicts-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	realDir := filepath.Join(dir, "real")
+	if err := os.Mkdir(realDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	linkDir := filepath.Join(dir, "link")
+	if err := os.Symlink(realDir, linkDir); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+
+	tc := NewTestCommand("")
+	tc.Cmd.FS = afero.NewOsFs()
+
+	in := filepath.Join(dir, "in.jsonnet")
+	JYOneTwo.WriteJ(t, tc.Cmd.FS, in)
+
+	err = tc.Cmd.Run(&jty.Flags{
+		Args: []string{
+			in, filepath.Join(realDir, "out.yml"),
+			in, filepath.Join(linkDir, "out.yml"),
+		},
+	})
+	if _, ok := err.(*jty.ConflictError); !ok {
+		t.Fatalf("expected *ConflictError, got %v", err)
+	}
+}
+
+func TestCommand_Conflicts_ImportedOutput(t *testing.T) {
+	libdir, err := ioutil.TempDir("", "jty-imports-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(libdir)
+
+	lib := filepath.Join(libdir, "lib.libsonnet")
+	if err := ioutil.WriteFile(lib, []byte("{X: 1}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := NewTestCommand("")
+	if err := afero.WriteFile(tc.FS, "in1.jsonnet", []byte(`[import 'lib.libsonnet']`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	JYSeq.WriteJ(t, tc.FS, "in2.jsonnet")
+
+	// The conflict is found before anything is written.
+	err = tc.Cmd.Run(&jty.Flags{
+		Args:   []string{"in1.jsonnet", "out1.yml", "in2.jsonnet", lib},
+		JPaths: []string{libdir},
+	})
+	cErr, ok := err.(*jty.ConflictError)
+	if !ok {
+		t.Fatalf("expected *ConflictError, got %v", err)
+	}
+	want := []string{"input in1.jsonnet of pair 1 imports " + lib + ", the output of pair 2"}
+	if !reflect.DeepEqual(cErr.Conflicts, want) {
+		t.Fatalf("expected conflicts %q, got %q", want, cErr.Conflicts)
+	}
+	if exists, _ := afero.Exists(tc.FS, "out1.yml"); exists {
+		t.Fatal("expected no output to be written")
+	}
+}
+
+func TestCommand_Conflicts_IndirectlyImportedOutput(t *testing.T) {
+	libdir, err := ioutil.TempDir("", "jty-imports-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(libdir)
+
+	for name, content := range map[string]string{
+		"outer.libsonnet": `{inner: import 'inner.libsonnet'}`,
+		"inner.libsonnet": `{data: importstr 'data.txt'}`,
+		"data.txt":        `old`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(libdir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := filepath.Join(libdir, "data.txt")
+
+	tc := NewTestCommand("")
+	JY{J: `[import 'outer.libsonnet']`}.WriteJ(t, tc.FS, "in1.jsonnet")
+	JYSeq.WriteJ(t, tc.FS, "in2.jsonnet")
+
+	err = tc.Cmd.Run(&jty.Flags{
+		Args:   []string{"in1.jsonnet", "out1.yml", "in2.jsonnet", data},
+		JPaths: []string{libdir},
+	})
+	cErr, ok := err.(*jty.ConflictError)
+	if !ok {
+		t.Fatalf("expected *ConflictError, got %v", err)
+	}
+	want := []string{"input in1.jsonnet of pair 1 imports " + data + ", the output of pair 2"}
+	if !reflect.DeepEqual(cErr.Conflicts, want) {
+		t.Fatalf("expected conflicts %q, got %q", want, cErr.Conflicts)
+	}
+}
+
+func TestCommand_Stdout(t *testing.T) {
+	tc := NewTestCommand("")
+	JYSeq.WriteJ(t, tc.FS, "in2.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args: []string{"in2.jsonnet", "-"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A single pair is written exactly as it would be to a file.
+	if tc.Stdout.String() != JYSeq.Y {
+		t.Fatalf("expected standard output %q, got %q", JYSeq.Y, tc.Stdout.String())
+	}
+	if _, err := tc.FS.Stat("-"); err == nil {
+		t.Fatal("expected no file named - to be written")
+	}
+}
+
+func TestCommand_Stdout_Several(t *testing.T) {
+	tc := NewTestCommand("")
+	JYSeq.WriteJ(t, tc.FS, "in.jsonnet")
+	JYOneTwo.WriteJ(t, tc.FS, "in2.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args: []string{"in.jsonnet", "-", "in2.jsonnet", "out.yml", "in.jsonnet", "-"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pair := "# Source: in.jsonnet\n" + strings.TrimSuffix(JYSeq.Y, "...\n")
+	if want := pair + pair + "...\n"; tc.Stdout.String() != want {
+		t.Fatalf("expected standard output %q, got %q", want, tc.Stdout.String())
+	}
+	JYOneTwo.ExpectY(t, tc.FS, "out.yml")
+}
+
+func TestCommand_Exec(t *testing.T) {
+	tc := NewTestCommand("")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args: []string{JYSeq.J, "out.yml", "[error 'boom']", "bad.yml"},
+		Exec: true,
+	}); err != jty.ErrEncounteredErrors {
+		t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+	}
+
+	JYSeq.ExpectY(t, tc.FS, "out.yml")
+
+	want := "failed to evaluate jsonnet at <cmdline>: RUNTIME ERROR: boom"
+	if !strings.Contains(tc.Stderr.String(), want) {
+		t.Fatalf("expected stderr to contain %q but it didn't: %q", want, tc.Stderr.String())
+	}
+}
+
+func TestCommand_StdinInput(t *testing.T) {
+	tc := NewTestCommand(JYOneTwo.J)
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		// Stdin is read once and may be used by several pairs.
+		Args: []string{"-", "out1.yml", "-", "out2.yml"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	JYOneTwo.ExpectY(t, tc.FS, "out1.yml")
+	JYOneTwo.ExpectY(t, tc.FS, "out2.yml")
+}
+
+func TestCommand_StdinInput_Errors(t *testing.T) {
+	t.Run("with stdin pairs", func(t *testing.T) {
+		tc := NewTestCommand("-\nout.yml\n")
+
+		if err := tc.Cmd.Run(&jty.Flags{FromStdin: true}); err != jty.ErrStdinInputAndPairs {
+			t.Fatalf("expected ErrStdinInputAndPairs, got %v", err)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		tc := NewTestCommand("")
+
+		if err := tc.Cmd.Run(&jty.Flags{Args: []string{"-", "out.yml"}}); err != jty.ErrEmptyCode {
+			t.Fatalf("expected ErrEmptyCode, got %v", err)
+		}
+	})
+}
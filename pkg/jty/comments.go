@@ -0,0 +1,189 @@
+package jty
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// With Processor.YAMLComments, object fields with these names are written as YAML comments instead of data:
+// "#" is a comment before the object, "#NAME" is a comment before field NAME,
+// and "//NAME" is a comment at the end of the line where field NAME begins.
+const (
+	yamlHeadCommentPrefix = "#"
+	yamlLineCommentPrefix = "//"
+)
+
+// yamlCommentTarget reports whether key is a comment field,
+// and if so, the field it comments on, or the empty string for the whole object,
+// and whether it is a line comment.
+func yamlCommentTarget(key string) (target string, line, ok bool) {
+	switch {
+	case strings.HasPrefix(key, yamlHeadCommentPrefix):
+		return key[len(yamlHeadCommentPrefix):], false, true
+	case strings.HasPrefix(key, yamlLineCommentPrefix):
+		return key[len(yamlLineCommentPrefix):], true, true
+	default:
+		return "", false, false
+	}
+}
+
+// stripYAMLComments returns a copy of v without any comment fields,
+// after checking that every comment field is a string and refers to a field that exists.
+// ptr is the JSON pointer of v, for error messages.
+func stripYAMLComments(ptr string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			target, line, ok := yamlCommentTarget(k)
+			if !ok {
+				s, err := stripYAMLComments(ptr+"/"+escapeJSONPointer(k), e)
+				if err != nil {
+					return nil, err
+				}
+				out[k] = s
+				continue
+			}
+
+			if _, isString := e.(string); !isString {
+				return nil, fmt.Errorf("%s: comment field %q must be a string, but is %s", jsonPointer(ptr), k, jsonTypeName(e))
+			}
+			_, exists := v[target]
+			_, _, targetIsComment := yamlCommentTarget(target)
+			if (!exists || targetIsComment) && (target != "" || line) {
+				return nil, fmt.Errorf("%s: comment field %q is for field %q, which doesn't exist", jsonPointer(ptr), k, target)
+			}
+		}
+		return out, nil
+
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			s, err := stripYAMLComments(fmt.Sprintf("%s/%d", ptr, i), e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = s
+		}
+		return out, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// stripYAMLCommentsAll strips the comment fields from each of docs.
+func stripYAMLCommentsAll(docs []interface{}) ([]interface{}, error) {
+	out := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		s, err := stripYAMLComments("", doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d at %s", i, err)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func jsonPointer(ptr string) string {
+	if ptr == "" {
+		return "/"
+	}
+	return ptr
+}
+
+// encodeCommentedYAML is like encodeYAML, but writes comment fields as YAML comments.
+func encodeCommentedYAML(w io.Writer, name string, docs []interface{}) error {
+	nodes := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		n, err := commentedYAMLNode(doc)
+		if err != nil {
+			return fmt.Errorf("error adding comments to YAML document %d when writing %s: %v", i, name, err)
+		}
+		nodes[i] = n
+	}
+	return encodeYAML(w, name, nodes)
+}
+
+// commentedYAMLNode returns the YAML node for doc with its comment fields attached as comments.
+func commentedYAMLNode(doc interface{}) (*yaml.Node, error) {
+	data, err := stripYAMLComments("", doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the node by encoding and parsing the data,
+	// so that it is laid out exactly as the encoder lays out the data alone.
+	b, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+	if n.Kind != yaml.DocumentNode || len(n.Content) != 1 {
+		return nil, fmt.Errorf("unexpected YAML node kind %v", n.Kind)
+	}
+
+	attachYAMLComments(n.Content[0], doc)
+	return n.Content[0], nil
+}
+
+// attachYAMLComments sets the comments in v, which has already been validated by stripYAMLComments,
+// on the node n that was encoded from v without its comment fields.
+func attachYAMLComments(n *yaml.Node, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		if c, ok := v[yamlHeadCommentPrefix].(string); ok {
+			n.HeadComment = yamlComment(c)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			if c, ok := v[yamlHeadCommentPrefix+key.Value].(string); ok {
+				key.HeadComment = yamlComment(c)
+			}
+			if c, ok := v[yamlLineCommentPrefix+key.Value].(string); ok {
+				// A comment on a block collection goes after its key, not after its first entry.
+				// The encoder writes a comment on a block scalar after its last line, or even inside it,
+				// so such a multi-line string is written double-quoted instead, with the comment after it.
+				if val.Kind == yaml.ScalarNode && val.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+					val.Style = yaml.DoubleQuotedStyle
+				}
+				if val.Kind == yaml.ScalarNode || val.Style&yaml.FlowStyle != 0 || len(val.Content) == 0 {
+					val.LineComment = yamlComment(c)
+				} else {
+					key.LineComment = yamlComment(c)
+				}
+			}
+			attachYAMLComments(val, v[key.Value])
+		}
+
+	case []interface{}:
+		if n.Kind != yaml.SequenceNode || len(n.Content) != len(v) {
+			return
+		}
+		for i, e := range v {
+			attachYAMLComments(n.Content[i], e)
+		}
+	}
+}
+
+// yamlComment returns the YAML comment text for s, with each line prefixed by "#".
+func yamlComment(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		if l == "" {
+			lines[i] = "#"
+		} else {
+			lines[i] = "# " + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
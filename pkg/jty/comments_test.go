@@ -0,0 +1,204 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func TestProcessor_YAMLComments(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAMLComments = true
+
+	jy := JY{
+		J: `[{
+  '#': 'Generated by app.jsonnet.\nDo not edit.',
+  '#replicas': 'Scaled by the autoscaler.',
+  replicas: 3,
+  '//image': 'pinned',
+  image: 'app:1.0',
+  '//env': 'from the environment',
+  env: {
+    '#': 'Only in production.',
+    DEBUG: 'false',
+  },
+  ports: [
+    { '#': 'HTTP', port: 80, '//port': 'standard' },
+  ],
+  empty: {},
+  '//empty': 'nothing here',
+  script: 'x\ny\n',
+  '//script': 'multi-line',
+  notes: 'p\nq\n',
+}, { plain: true }]`,
+		Y: `---
+# Generated by app.jsonnet.
+# Do not edit.
+empty: {} # nothing here
+env: # from the environment
+    # Only in production.
+    DEBUG: "false"
+image: app:1.0 # pinned
+notes: |
+    p
+    q
+ports:
+  # HTTP
+  - port: 80 # standard
+# Scaled by the autoscaler.
+replicas: 3
+script: "x\ny\n" # multi-line
+---
+plain: true
+...
+`,
+	}
+	jy.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	if log.Len() > 0 {
+		t.Fatalf("expected no log output, got %q", log.String())
+	}
+	jy.ExpectY(t, fs, "out.yml")
+}
+
+func TestProcessor_YAMLComments_Disabled(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	JY{J: `[{'#a': 'comment', a: 1}]`}.WriteJ(t, fs, "in.jsonnet")
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	JY{Y: "---\n'#a': comment\na: 1\n...\n"}.ExpectY(t, fs, "out.yml")
+}
+
+func TestProcessor_YAMLComments_OtherFormats(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAMLComments = true
+
+	JY{J: `[{'#': 'top', '#a': 'comment', a: 1, '//b': 'line', b: {c: 2}}]`}.WriteJ(t, fs, "in.jsonnet")
+	p.Process("in.jsonnet", "out.toml")
+	p.Close()
+
+	if log.Len() > 0 {
+		t.Fatalf("expected no log output, got %q", log.String())
+	}
+	JY{Y: "a = 1\n\n[b]\nc = 2\n"}.ExpectY(t, fs, "out.toml")
+}
+
+func TestProcessor_YAMLComments_Errors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		j, want  string
+		kubeSort bool
+	}{
+		"missing field": {
+			j:    `[{a: {'#b': 'comment', c: 1}}]`,
+			want: `document 0 at /a: comment field "#b" is for field "b", which doesn't exist`,
+		},
+		"not a string": {
+			j:    `[{'//a': 1, a: 1}]`,
+			want: `document 0 at /: comment field "//a" must be a string, but is a number`,
+		},
+		"comment on a comment": {
+			j:    `[{'##a': 'x', '#a': 'y', a: 1}]`,
+			want: `document 0 at /: comment field "##a" is for field "#a", which doesn't exist`,
+		},
+		"comment on a List field": {
+			j:        `[{kind: 'List', '//kind': 'x', items: [{kind: 'Service'}]}]`,
+			want:     `List has comment field "//kind", which can't be written once its items are expanded`,
+			kubeSort: true,
+		},
+		"comment on an empty List": {
+			j:        `[{kind: 'List', '#': 'x', items: []}]`,
+			want:     `List has comment field "#", but no object item to carry it`,
+			kubeSort: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			log := new(bytes.Buffer)
+			p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+			p.YAMLComments = true
+			p.KubeSort = tc.kubeSort
+
+			JY{J: tc.j}.WriteJ(t, fs, "in.jsonnet")
+			p.Process("in.jsonnet", "out.yml")
+			p.Close()
+
+			if !strings.Contains(log.String(), tc.want) {
+				t.Fatalf("expected log to contain %q, got %q", tc.want, log.String())
+			}
+		})
+	}
+}
+
+func TestProcessor_YAMLComments_KubeList(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAMLComments = true
+	p.KubeSort = true
+
+	// The List's comment goes on its first item, wherever that is sorted to.
+	jy := JY{
+		J: `[{
+  '#': 'Everything for app.',
+  kind: 'List',
+  items: [
+    { '#': 'The service.', kind: 'Service', metadata: { name: 'app' } },
+    { kind: 'ConfigMap', metadata: { name: 'app' } },
+  ],
+}]`,
+		Y: `---
+kind: ConfigMap
+metadata:
+    name: app
+---
+# Everything for app.
+# The service.
+kind: Service
+metadata:
+    name: app
+...
+`,
+	}
+	jy.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	if log.Len() > 0 {
+		t.Fatalf("expected no log output, got %q", log.String())
+	}
+	jy.ExpectY(t, fs, "out.yml")
+}
+
+func TestCommand_YAMLComments_Schema(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `{"type": "object", "properties": {"a": {"type": "integer"}}, "additionalProperties": false}`}.WriteJ(t, tc.FS, "schema.json")
+	JY{J: `[{'#a': 'validated without this field', a: 1}]`}.WriteJ(t, tc.FS, "in.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args:         []string{"in.jsonnet", "out.yml"},
+		Schemas:      []string{"*.yml=schema.json"},
+		YAMLComments: true,
+		YAML11Lint:   jty.YAML11LintFail,
+	}); err != nil {
+		t.Fatalf("unexpected error %v: %s", err, tc.Stderr.String())
+	}
+
+	JY{Y: "---\n# validated without this field\na: 1\n...\n"}.ExpectY(t, tc.FS, "out.yml")
+}
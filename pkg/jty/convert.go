@@ -0,0 +1,314 @@
+package jty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/spf13/afero"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// YAMLToJsonnet converts the YAML stream in content to Jsonnet
+// that evaluates to an array of the stream's documents,
+// so that jty reproduces the stream when given the result.
+//
+// Mapping keys stay in their original order,
+// and multi-line strings become text blocks where Jsonnet allows it.
+func YAMLToJsonnet(content []byte) (string, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+
+	var buf bytes.Buffer
+	buf.WriteString("[\n")
+	for i := 0; ; i++ {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("error parsing YAML document %d: %v", i, err)
+		}
+
+		buf.WriteString("  ")
+		if err := writeJsonnetValue(&buf, &doc, 1); err != nil {
+			return "", fmt.Errorf("error converting YAML document %d: %v", i, err)
+		}
+		buf.WriteString(",\n")
+	}
+	buf.WriteString("]\n")
+
+	if buf.String() == "[\n]\n" {
+		return "[]\n", nil
+	}
+	return buf.String(), nil
+}
+
+// writeJsonnetValue writes n as a Jsonnet value,
+// assuming the current line is already indented to depth.
+func writeJsonnetValue(buf *bytes.Buffer, n *yaml.Node, depth int) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJsonnetValue(buf, n.Content[0], depth)
+
+	case yaml.AliasNode:
+		return writeJsonnetValue(buf, n.Alias, depth)
+
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+
+		buf.WriteString("{\n")
+		for i := 0; i < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind == yaml.AliasNode {
+				k = k.Alias
+			}
+			if k.Tag == "!!merge" {
+				return fmt.Errorf("line %d: merge keys are not supported", k.Line)
+			}
+
+			var key interface{}
+			if err := k.Decode(&key); err != nil {
+				return fmt.Errorf("line %d: %v", k.Line, err)
+			}
+			ks, ok := key.(string)
+			if !ok {
+				return fmt.Errorf("line %d: mapping key %v is not a string", k.Line, key)
+			}
+
+			writeIndent(buf, depth+1)
+			buf.WriteString(jsonnetFieldName(ks))
+			buf.WriteString(": ")
+			if err := writeJsonnetValue(buf, v, depth+1); err != nil {
+				return err
+			}
+			buf.WriteString(",\n")
+		}
+		writeIndent(buf, depth)
+		buf.WriteString("}")
+		return nil
+
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+
+		buf.WriteString("[\n")
+		for _, e := range n.Content {
+			writeIndent(buf, depth+1)
+			if err := writeJsonnetValue(buf, e, depth+1); err != nil {
+				return err
+			}
+			buf.WriteString(",\n")
+		}
+		writeIndent(buf, depth)
+		buf.WriteString("]")
+		return nil
+
+	case yaml.ScalarNode:
+		v, err := yamlScalar(n)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n.Line, err)
+		}
+
+		if s, ok := v.(string); ok {
+			writeJsonnetString(buf, s, depth)
+			return nil
+		}
+		if f, ok := v.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return fmt.Errorf("line %d: %s cannot be represented in Jsonnet", n.Line, n.Value)
+		}
+
+		// Everything else is written the same way that plain YAML inputs are converted to JSON,
+		// so timestamps stay strings and numbers keep their digits.
+		j, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n.Line, err)
+		}
+		buf.Write(j)
+		return nil
+
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node kind %v", n.Line, n.Kind)
+	}
+}
+
+func writeIndent(buf *bytes.Buffer, depth int) {
+	buf.WriteString(strings.Repeat("  ", depth))
+}
+
+// writeJsonnetString writes s as a text block if it is a multi-line string
+// that a text block can represent exactly, or as a single-quoted string otherwise.
+func writeJsonnetString(buf *bytes.Buffer, s string, depth int) {
+	if !canBeTextBlock(s) {
+		buf.WriteString(quoteJsonnetString(s))
+		return
+	}
+
+	buf.WriteString("|||\n")
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line == "" {
+			// After the final newline.
+			continue
+		}
+		if line != "\n" {
+			writeIndent(buf, depth+1)
+		}
+		buf.WriteString(line)
+	}
+	writeIndent(buf, depth)
+	buf.WriteString("|||")
+}
+
+// canBeTextBlock reports whether s can be written as a text block.
+// Text blocks always end with a newline,
+// and their indentation is determined by their first line.
+func canBeTextBlock(s string) bool {
+	if !strings.HasSuffix(s, "\n") || strings.Count(s, "\n") < 2 {
+		return false
+	}
+	if strings.ContainsAny(s, "\r") {
+		return false
+	}
+	switch s[0] {
+	case ' ', '\t', '\n':
+		return false
+	}
+	return true
+}
+
+// quoteJsonnetString returns s as a single-quoted Jsonnet string literal.
+func quoteJsonnetString(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+var jsonnetIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var jsonnetKeywords = map[string]bool{
+	"assert": true, "else": true, "error": true, "false": true, "for": true,
+	"function": true, "if": true, "import": true, "importstr": true, "in": true,
+	"local": true, "null": true, "tailstrict": true, "then": true, "self": true,
+	"super": true, "true": true,
+}
+
+// jsonnetFieldName returns name as a Jsonnet field name,
+// quoting it only if necessary.
+func jsonnetFieldName(name string) string {
+	if jsonnetIdentifier.MatchString(name) && !jsonnetKeywords[name] {
+		return name
+	}
+	return quoteJsonnetString(name)
+}
+
+// checkRoundTrip evaluates the generated Jsonnet
+// and confirms that jty writes exactly the same YAML for it as for the YAML it was generated from.
+func checkRoundTrip(filename, code string, yamlContent []byte) error {
+	wantJSONs, err := yamlDocuments(yamlContent)
+	if err != nil {
+		return err
+	}
+	gotJSONs, err := jsonnet.MakeVM().EvaluateSnippetStream(filename, code)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate generated Jsonnet: %v", err)
+	}
+	if len(gotJSONs) != len(wantJSONs) {
+		return fmt.Errorf("generated Jsonnet produced %d documents, but the YAML has %d", len(gotJSONs), len(wantJSONs))
+	}
+
+	for i := range wantJSONs {
+		want, err := jsonToYAML(filename, wantJSONs[i])
+		if err != nil {
+			return err
+		}
+		got, err := jsonToYAML(filename, gotJSONs[i])
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("document %d differs after conversion: got\n%swant\n%s", i, got, want)
+		}
+	}
+
+	return nil
+}
+
+// jsonToYAML returns the JSON document j as jty writes it in YAML output.
+func jsonToYAML(name, j string) (string, error) {
+	docs, err := decodeJSONs(name, []string{j})
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, name, docs); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Import converts the YAML file at f.Args[0] to Jsonnet saved at f.Args[1].
+func (c *Command) Import(f *ImportFlags) error {
+	if len(f.Args) != 2 {
+		return ErrImportArgs
+	}
+	inPath, outPath := f.Args[0], f.Args[1]
+
+	content, err := afero.ReadFile(c.FS, inPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", inPath, err)
+	}
+
+	code, err := YAMLToJsonnet(content)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %v", inPath, err)
+	}
+
+	if f.Check {
+		if err := checkRoundTrip(outPath, code, content); err != nil {
+			return fmt.Errorf("round trip check of %s failed: %v", inPath, err)
+		}
+	}
+
+	if outPath == StdoutPath {
+		_, err := io.WriteString(c.Stdout, code)
+		return err
+	}
+	if err := afero.WriteFile(c.FS, outPath, []byte(code), 0666); err != nil {
+		return fmt.Errorf("failed to write %s: %v", outPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package jty_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+const importYAML = `# A comment that is dropped.
+zebra: 1
+apple: &shared
+  - x
+  - 'it''s'
+if: true
+with-dash: null
+script: |
+  #!/bin/sh
+  echo hi
+
+  exit 0
+inline: "a\nb"
+empty_map: {}
+empty_list: []
+when: 2001-12-14
+ref: *shared
+---
+- 1.5
+- -3
+- "0755"
+`
+
+func TestYAMLToJsonnet(t *testing.T) {
+	got, err := jty.YAMLToJsonnet([]byte(importYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[
+  {
+    zebra: 1,
+    apple: [
+      'x',
+      'it\'s',
+    ],
+    'if': true,
+    'with-dash': null,
+    script: |||
+      #!/bin/sh
+      echo hi
+
+      exit 0
+    |||,
+    inline: 'a\nb',
+    empty_map: {},
+    empty_list: [],
+    when: '2001-12-14',
+    ref: [
+      'x',
+      'it\'s',
+    ],
+  },
+  [
+    1.5,
+    -3,
+    '0755',
+  ],
+]
+`
+	if got != want {
+		t.Fatalf("unexpected Jsonnet:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestYAMLToJsonnet_Empty(t *testing.T) {
+	got, err := jty.YAMLToJsonnet(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "[]\n" {
+		t.Fatalf("expected empty array, got %q", got)
+	}
+}
+
+func TestYAMLToJsonnet_Errors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		yaml string
+		want string
+	}{
+		"merge key": {
+			yaml: "base: &b {a: 1}\nderived:\n  <<: *b\n",
+			want: "error converting YAML document 0: line 3: merge keys are not supported",
+		},
+		"non-string key": {
+			yaml: "---\n---\n1: one\n",
+			want: "error converting YAML document 1: line 3: mapping key 1 is not a string",
+		},
+		"infinity": {
+			yaml: "- .inf\n",
+			want: "error converting YAML document 0: line 1: .inf cannot be represented in Jsonnet",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := jty.YAMLToJsonnet([]byte(tt.yaml))
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCommand_Import_RoundTrip(t *testing.T) {
+	tc := NewTestCommand("")
+	if err := afero.WriteFile(tc.FS, "in.yaml", []byte(importYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.Cmd.Import(&jty.ImportFlags{
+		Args:  []string{"in.yaml", "out.jsonnet"},
+		Check: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running jty over the generated Jsonnet and over the original YAML must produce the same output.
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args: []string{"out.jsonnet", "from-jsonnet.yml", "in.yaml", "from-yaml.yml"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	fromYAML, err := afero.ReadFile(tc.FS, "from-yaml.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	JY{Y: string(fromYAML)}.ExpectY(t, tc.FS, "from-jsonnet.yml")
+}
+
+func TestCommand_Import_Scalars(t *testing.T) {
+	tc := NewTestCommand("")
+	if err := afero.WriteFile(tc.FS, "d.yaml", []byte("c: 2001-12-14\nb: 12345678901234567890123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.Cmd.Import(&jty.ImportFlags{Args: []string{"d.yaml", "d.jsonnet"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := afero.ReadFile(tc.FS, "d.jsonnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[\n  {\n    c: '2001-12-14',\n    b: 12345678901234567890123,\n  },\n]\n"; string(got) != want {
+		t.Fatalf("expected Jsonnet %q, got %q", want, got)
+	}
+
+	// Jsonnet numbers are doubles, so evaluating the result can't reproduce every digit.
+	err = tc.Cmd.Import(&jty.ImportFlags{Args: []string{"d.yaml", "checked.jsonnet"}, Check: true})
+	want := "round trip check of d.yaml failed: document 0 differs after conversion: got\n---\nb: 12345678901234567741440\n"
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("expected error starting %q, got %v", want, err)
+	}
+	if exists, _ := afero.Exists(tc.FS, "checked.jsonnet"); exists {
+		t.Fatal("expected checked.jsonnet not to be written")
+	}
+}
+
+func TestCommand_Import_Args(t *testing.T) {
+	tc := NewTestCommand("")
+
+	if err := tc.Cmd.Import(&jty.ImportFlags{Args: []string{"in.yaml"}}); err != jty.ErrImportArgs {
+		t.Fatalf("expected ErrImportArgs, got %v", err)
+	}
+}
+
+func TestCommand_Import_Stdout(t *testing.T) {
+	tc := NewTestCommand("")
+	if err := afero.WriteFile(tc.FS, "in.yaml", []byte("a: 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.Cmd.Import(&jty.ImportFlags{Args: []string{"in.yaml", "-"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[\n  {\n    a: 1,\n  },\n]\n"
+	if got := tc.Stdout.String(); got != want {
+		t.Fatalf("expected stdout %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,170 @@
+package jty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// isDataInput reports whether the file at path holds plain data (JSON or YAML)
+// that is to be reformatted, rather than Jsonnet to be evaluated.
+func isDataInput(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// dataToJSONs parses the JSON or YAML content read from path
+// and returns each document in it encoded as JSON.
+//
+// Every top-level JSON value is a separate document,
+// so a JSON file containing an array produces a single document holding that array.
+func dataToJSONs(path string, content []byte) ([]string, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return jsonValues(content)
+	}
+	return yamlDocuments(content)
+}
+
+func jsonValues(content []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+
+	var jsons []string
+	for i := 0; ; i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return jsons, nil
+			}
+			return nil, fmt.Errorf("error parsing JSON value %d: %v", i, err)
+		}
+		jsons = append(jsons, string(raw))
+	}
+}
+
+func yamlDocuments(content []byte) ([]string, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+
+	var jsons []string
+	for i := 0; ; i++ {
+		var node yaml.Node
+		if err := dec.Decode(&node); err != nil {
+			if err == io.EOF {
+				return jsons, nil
+			}
+			return nil, fmt.Errorf("error parsing YAML document %d: %v", i, err)
+		}
+
+		var doc yamlData
+		if err := node.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("YAML document %d cannot be represented as JSON: %v", i, err)
+		}
+
+		j, err := json.Marshal(doc.v)
+		if err != nil {
+			return nil, fmt.Errorf("YAML document %d cannot be represented as JSON: %v", i, err)
+		}
+		jsons = append(jsons, string(j))
+	}
+}
+
+// yamlData is a YAML value decoded as it would be written in JSON.
+// Unlike decoding into an interface{}, numbers keep their digits
+// and timestamps stay the strings they were written as, so reformatting doesn't change them.
+type yamlData struct {
+	v interface{}
+}
+
+func (d *yamlData) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		// Decoding into a map resolves merge keys.
+		// Elements are pointers because the decoder drops nulls it can't store, leaving them nil.
+		var m map[yamlKey]*yamlData
+		if err := n.Decode(&m); err != nil {
+			return err
+		}
+		obj := make(map[string]interface{}, len(m))
+		for k, e := range m {
+			obj[string(k)] = e.value()
+		}
+		d.v = obj
+	case yaml.SequenceNode:
+		var s []*yamlData
+		if err := n.Decode(&s); err != nil {
+			return err
+		}
+		arr := make([]interface{}, len(s))
+		for i, e := range s {
+			arr[i] = e.value()
+		}
+		d.v = arr
+	case yaml.ScalarNode:
+		v, err := yamlScalar(n)
+		if err != nil {
+			return err
+		}
+		d.v = v
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node kind %v", n.Line, n.Kind)
+	}
+	return nil
+}
+
+// value returns the decoded value of d, which is nil for a null.
+func (d *yamlData) value() interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.v
+}
+
+// yamlKey is a YAML mapping key, which must be a string to be represented as JSON.
+type yamlKey string
+
+func (k *yamlKey) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		switch n.ShortTag() {
+		case "!!str", "!!timestamp":
+			*k = yamlKey(n.Value)
+			return nil
+		}
+	}
+
+	var key interface{}
+	if err := n.Decode(&key); err != nil {
+		return err
+	}
+	return fmt.Errorf("mapping key %v is not a string", key)
+}
+
+var jsonNumberSyntax = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// yamlScalar returns the value of the scalar n as it is to be written in JSON.
+// An int or float written as a valid JSON number is returned as a json.Number with the same digits,
+// and a timestamp is returned as the string it was written as.
+func yamlScalar(n *yaml.Node) (interface{}, error) {
+	switch n.ShortTag() {
+	case "!!int", "!!float":
+		if jsonNumberSyntax.MatchString(n.Value) {
+			return json.Number(n.Value), nil
+		}
+	case "!!timestamp":
+		return n.Value, nil
+	}
+
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,101 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func TestProcessor_DataInputs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	for path, content := range map[string]string{
+		"in.json":  `{"z": [1, 2], "a": {"nested": true}} {"second": "doc"}`,
+		"in.yaml":  "z: [1, 2]\na: {nested: true}\n---\nsecond: doc\n",
+		"in.yml":   "- just\n- a list\n",
+		"bad.yaml": "a: [\n",
+		"keys.yml": "{1: one}\n",
+	} {
+		if err := afero.WriteFile(fs, path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.Process("in.json", "json.yml")
+	p.Process("in.yaml", "yaml.yml")
+	p.Process("in.yml", "yml.yml")
+	p.Process("bad.yaml", "bad.yml")
+	p.Process("keys.yml", "keys.out.yml")
+	p.Close()
+
+	want := `---
+a:
+    nested: true
+z:
+  - 1
+  - 2
+---
+second: doc
+...
+`
+	JY{Y: want}.ExpectY(t, fs, "json.yml")
+	JY{Y: want}.ExpectY(t, fs, "yaml.yml")
+	JY{Y: "---\n- just\n- a list\n...\n"}.ExpectY(t, fs, "yml.yml")
+
+	out := log.String()
+	for _, want := range []string{
+		"failed to parse bad.yaml: error parsing YAML document 0: ",
+		"failed to parse keys.yml: YAML document 0 cannot be represented as JSON: mapping key 1 is not a string",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected log %q to contain %q but it didn't", out, want)
+		}
+	}
+}
+
+func TestProcessor_DataInputs_Scalars(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	in := `date: 2001-12-14
+time: 2001-12-14t21:59:43.10-05:00
+big: 12345678901234567890123
+exp: 1.5e3
+hex: 0x1F
+base: &b {x: 1}
+merged:
+  <<: *b
+  y: 2
+`
+	if err := afero.WriteFile(fs, "in.yaml", []byte(in), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Process("in.yaml", "out.yml")
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Fatalf("expected empty log, got %q", got)
+	}
+	JY{Y: `---
+base:
+    x: 1
+big: 12345678901234567890123
+date: "2001-12-14"
+exp: 1.5e3
+hex: 31
+merged:
+    x: 1
+    y: 2
+time: "2001-12-14t21:59:43.10-05:00"
+...
+`}.ExpectY(t, fs, "out.yml")
+}
@@ -6,16 +6,96 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// Values for Flags.StdinFormat.
+const (
+	// Alternating input and output paths, separated by newlines or NULs.
+	StdinFormatPairs = "pairs"
+
+	// One JSON object per line, describing a job.
+	StdinFormatJSONL = "jsonl"
+)
+
 // Flags are the command-line flags jty supports.
 type Flags struct {
 	Args []string // The positional arguments.
 
 	DryRun    bool
+	Exec      bool
 	FromStdin bool
 	Zero      bool
 
+	// How jobs are described on stdin; one of the StdinFormat constants.
+	StdinFormat string
+
 	HelpRequested bool
 
+	Verbose bool
+	Quiet   bool
+
+	// Mark output files as generated, so they can be recognized by Prune.
+	Mark bool
+
+	// Output format for every pair that doesn't specify its own; one of the Format constants.
+	// Empty means the format implied by each output path's extension.
+	Format string
+
+	// Template for a comment written at the top of each output file.
+	Header string
+
+	// GLOB=FILE pairs selecting JSON Schemas by output path.
+	Schemas []string
+
+	// APIVERSION/KIND=FILE pairs selecting JSON Schemas by Kubernetes object type.
+	KindSchemas []string
+
+	// Kubernetes-aware output handling.
+	KubeSort  bool
+	KubeSplit bool
+
+	// Write object fields named "#", "#NAME", and "//NAME" as YAML comments.
+	YAMLComments bool
+
+	// How to report YAML output that YAML 1.1 parsers would read differently; one of the YAML11Lint constants.
+	// Empty disables the check.
+	YAML11Lint string
+
+	// Check or fix the formatting of Jsonnet inputs, and optionally of the files they import.
+	FmtCheck   bool
+	Fmt        bool
+	FmtImports bool
+
+	// Lint Jsonnet inputs and their imports,
+	// failing the run for findings at or above LintFailOn, one of the LintSeverity constants.
+	Lint       bool
+	LintFailOn string
+
+	// Register jty's built-in native functions.
+	Natives bool
+
+	// Directories to remove stale generated files from. Implies Mark.
+	Prune []string
+
+	// If not empty, the positional arguments are all inputs,
+	// and each output path is given by this template.
+	Out string
+
+	// NAME=V1,V2 values: each input is evaluated once for every combination of values,
+	// bound as external variables or top-level arguments. Requires Out.
+	Matrix    []string
+	MatrixTLA []string
+
+	// The octal permissions of output files, like 0644, or empty for the default.
+	Mode string
+
+	// Keep the permissions of output files that already exist.
+	KeepMode bool
+
+	// If not empty, a JUnit XML report of every pair is written to this path.
+	JUnit string
+
+	// If not empty, imports must resolve to files within these directories or JPaths.
+	ImportRoots []string
+
 	// Parsed --jpath values (in given order)
 	// prefixed with JSONNET_PATH environment variable values in reverse order.
 	// Same behavior as official jsonnet tool.
@@ -25,23 +105,65 @@ type Flags struct {
 // AddToFlagSet associates f with the given FlagSet.
 func (f *Flags) AddToFlagSet(s *pflag.FlagSet) {
 	s.BoolVarP(&f.DryRun, "dry-run", "n", false, "Print to stdout what processing would be done, without touching any files on disk.")
+	s.BoolVarP(&f.Exec, "exec", "e", false, "Treat each input as Jsonnet code rather than a file path.")
 	s.BoolVarP(&f.FromStdin, "stdin", "i", false, "Read the input-output pairs of files from stdin.")
 	s.BoolVarP(&f.Zero, "zero", "z", false, "Expect NUL-separated input-output pairs from stdin. Implies -i.")
+	s.StringVar(&f.StdinFormat, "stdin-format", StdinFormatPairs, `How jobs are given on stdin: "pairs" of input and output paths, or "jsonl" objects like {"in": "a.jsonnet", "out": "a.yml", "tla": {}, "ext": {}, "format": "yaml", "mode": "0644"}. Implies -i.`)
 	s.BoolVarP(&f.HelpRequested, "help", "h", false, "Show help.")
+	s.BoolVarP(&f.Verbose, "verbose", "v", false, "Log each output file as it is written, with its duration and size.")
+	s.BoolVarP(&f.Quiet, "quiet", "q", false, "Log nothing; only report success or failure through the exit code.")
+
+	s.BoolVar(&f.Mark, "mark", false, "Begin each output file with a comment marking it as generated by jty.")
+	s.StringVar(&f.Format, "format", "", `Output format: "yaml", "toml", or "ini". By default, outputs ending in .toml or .ini are written in that format, and all others as YAML.`)
+	s.StringVar(&f.Header, "header", "", "Template for a comment at the top of each output file, e.g. 'Generated from {{.InPath}}; do not edit.' Each line is prefixed with '# '.")
+	s.StringArrayVar(&f.Schemas, "schema", nil, "Validate documents written to outputs matching GLOB against the JSON Schema in FILE, given as GLOB=FILE. May be repeated.")
+	s.StringArrayVar(&f.KindSchemas, "kind-schema", nil, "Validate Kubernetes objects of the given type against the JSON Schema in FILE, given as APIVERSION/KIND=FILE (e.g. apps/v1/Deployment=deployment.json). May be repeated.")
+	s.BoolVar(&f.KubeSort, "kube-sort", false, "Expand Kubernetes List objects and sort documents: Namespaces, then CustomResourceDefinitions, then by kind, namespace, and name.")
+	s.BoolVar(&f.KubeSplit, "kube-split", false, "Expand Kubernetes List objects and treat each output path as a directory, writing every object to {namespace}/{kind}-{name}.yaml within it.")
+	s.StringArrayVar(&f.Prune, "prune", nil, "After a successful run, delete files under this directory that are marked as generated by jty but were not produced by any pair. Implies --mark. May be repeated.")
+	s.StringVar(&f.Out, "out", "", "Treat every positional argument as an input, and write its output to this path template, where {stem} is the input's file name without its extension, {dir} is its directory, and {NAME} is the value of a matrix variable.")
+	s.StringArrayVar(&f.Matrix, "matrix", nil, "Evaluate each input once for each value of the external variable NAME, given as NAME=V1,V2,... Requires --out. May be repeated to evaluate every combination.")
+	s.StringArrayVar(&f.MatrixTLA, "matrix-tla", nil, "Like --matrix, but binds NAME as a top-level argument.")
+	s.StringVar(&f.Mode, "mode", "", `Octal permissions of output files, like "0644" or "0755", regardless of the umask. By default, new files are created with 0666 minus the umask.`)
+	s.BoolVar(&f.KeepMode, "keep-mode", false, "Keep the permissions of output files that already exist; --mode then only applies to new files.")
+	s.StringVar(&f.JUnit, "junit", "", "Write a JUnit XML report to this file, with a test case for each pair that fails with the errors logged for it.")
 
+	s.BoolVar(&f.YAMLComments, "yaml-comments", false, `Write object fields named "#" as a YAML comment before the object, "#NAME" as a comment before field NAME, and "//NAME" as a comment at the end of field NAME's line, instead of as data.`)
+	s.StringVar(&f.YAML11Lint, "yaml11-lint", "", `Parse each YAML output again as YAML 1.1 and report values it would read differently, such as on, yes, or 0755: "warn" logs them, "fail" fails the pair.`)
+	s.BoolVar(&f.FmtCheck, "fmt-check", false, "Report every Jsonnet input that is not formatted like jsonnetfmt would format it, and fail.")
+	s.BoolVar(&f.Fmt, "fmt", false, "Reformat Jsonnet inputs in place, like jsonnetfmt -i.")
+	s.BoolVar(&f.FmtImports, "fmt-imports", false, "Apply --fmt or --fmt-check to imported .jsonnet and .libsonnet files too, except those in library search paths.")
+	s.BoolVar(&f.Lint, "lint", false, "Lint each Jsonnet input and the files it imports with go-jsonnet's linter after evaluating it.")
+	s.StringVar(&f.LintFailOn, "lint-fail-on", LintSeverityError, `Lowest severity of lint finding that fails the run: "warning" (unused variables), "error" (everything else), or "none".`)
+	s.BoolVar(&f.Natives, "natives", false, nativesUsage)
+
+	s.StringArrayVar(&f.ImportRoots, "import-root", nil, importRootUsage)
 	s.StringArrayVarP(&f.JPaths, "jpath", "J", nil, "Additional library search paths (rightmost wins).")
 }
 
+const importRootUsage = "Refuse to import files that resolve outside this directory, after following symlinks. Library search paths are always allowed. May be repeated."
+
+const nativesUsage = "Enable jty's built-in native functions for std.native: sha256File, base64Gzip, semverCompare, cidrSubnet, and cidrHost."
+
 // FinishParse sets any default values that are implied by another option,
 // and parses any supplied environment values.
 //
 // jsonnetPathEnv is the value of environment variable JSONNET_PATH.
 func (f *Flags) FinishParse(jsonnetPathEnv string) {
-	if f.Zero {
+	if f.Zero || (f.StdinFormat != "" && f.StdinFormat != StdinFormatPairs) {
 		f.FromStdin = true
 	}
+	if len(f.Prune) > 0 {
+		f.Mark = true
+	}
 
-	e := filepath.SplitList(jsonnetPathEnv)
+	f.JPaths = prependJsonnetPath(jsonnetPathEnv, f.JPaths)
+}
+
+// prependJsonnetPath returns jpaths prefixed with the entries of
+// the JSONNET_PATH environment variable value env, in reverse order.
+func prependJsonnetPath(env string, jpaths []string) []string {
+	e := filepath.SplitList(env)
 
 	// Reverse the list. https://github.com/golang/go/wiki/SliceTricks#reversing
 	for i := len(e)/2 - 1; i >= 0; i-- {
@@ -49,5 +171,87 @@ func (f *Flags) FinishParse(jsonnetPathEnv string) {
 		e[i], e[opp] = e[opp], e[i]
 	}
 
-	f.JPaths = append(e, f.JPaths...)
+	return append(e, jpaths...)
+}
+
+// ImportFlags are the command-line flags for the import subcommand.
+type ImportFlags struct {
+	Args []string // The positional arguments: input YAML and output Jsonnet.
+
+	// Confirm that the generated Jsonnet evaluates to the original YAML before writing it.
+	Check bool
+
+	HelpRequested bool
+}
+
+// AddToFlagSet associates f with the given FlagSet.
+func (f *ImportFlags) AddToFlagSet(s *pflag.FlagSet) {
+	s.BoolVar(&f.Check, "check", false, "Evaluate the generated Jsonnet and fail without writing it if it does not reproduce the input YAML.")
+	s.BoolVarP(&f.HelpRequested, "help", "h", false, "Show help.")
+}
+
+// ServeFlags are the command-line flags for the serve subcommand.
+type ServeFlags struct {
+	// Path of a Unix socket to listen on. If empty, serve on stdin and stdout.
+	Socket string
+
+	HelpRequested bool
+
+	// Same as Flags.Natives.
+	Natives bool
+
+	// Same as Flags.ImportRoots.
+	ImportRoots []string
+
+	// Same as Flags.JPaths.
+	JPaths []string
+}
+
+// AddToFlagSet associates f with the given FlagSet.
+func (f *ServeFlags) AddToFlagSet(s *pflag.FlagSet) {
+	s.StringVar(&f.Socket, "socket", "", "Listen for connections on this Unix socket instead of serving a single client on stdin and stdout.")
+	s.BoolVarP(&f.HelpRequested, "help", "h", false, "Show help.")
+	s.BoolVar(&f.Natives, "natives", false, nativesUsage)
+
+	s.StringArrayVar(&f.ImportRoots, "import-root", nil, importRootUsage)
+	s.StringArrayVarP(&f.JPaths, "jpath", "J", nil, "Additional library search paths (rightmost wins).")
+}
+
+// FinishParse parses any supplied environment values.
+//
+// jsonnetPathEnv is the value of environment variable JSONNET_PATH.
+func (f *ServeFlags) FinishParse(jsonnetPathEnv string) {
+	f.JPaths = prependJsonnetPath(jsonnetPathEnv, f.JPaths)
+}
+
+// TestFlags are the command-line flags for the test subcommand.
+type TestFlags struct {
+	Args []string // The positional arguments: test files, or directories to search for them.
+
+	// How to report results; one of the TestFormat constants.
+	Format string
+
+	HelpRequested bool
+
+	// Same as Flags.Natives.
+	Natives bool
+
+	// Same as Flags.JPaths.
+	JPaths []string
+}
+
+// AddToFlagSet associates f with the given FlagSet.
+func (f *TestFlags) AddToFlagSet(s *pflag.FlagSet) {
+	s.StringVar(&f.Format, "format", TestFormatTAP, `Report format: "tap" or "junit" (XML).`)
+	s.BoolVarP(&f.HelpRequested, "help", "h", false, "Show help.")
+	s.BoolVar(&f.Natives, "natives", false, nativesUsage)
+
+	s.StringArrayVarP(&f.JPaths, "jpath", "J", nil, "Additional library search paths (rightmost wins).")
+}
+
+// FinishParse parses any supplied environment values.
+//
+// jsonnetPathEnv is the value of environment variable JSONNET_PATH.
+func (f *TestFlags) FinishParse(jsonnetPathEnv string) {
+	f.JPaths = prependJsonnetPath(jsonnetPathEnv, f.JPaths)
 }
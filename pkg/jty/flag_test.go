@@ -82,3 +82,34 @@ func TestFlags_JPaths(t *testing.T) {
 		}
 	})
 }
+
+func TestFlags_PruneSetsMark(t *testing.T) {
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	var f jty.Flags
+	f.AddToFlagSet(fs)
+	if err := fs.Parse([]string{"--prune", "gen"}); err != nil {
+		t.Fatal(err)
+	}
+	f.FinishParse("")
+
+	if !f.Mark {
+		t.Fatal("expected --prune to set Mark, but it didn't")
+	}
+	if !reflect.DeepEqual(f.Prune, []string{"gen"}) {
+		t.Fatalf("expected Prune [gen], got %v", f.Prune)
+	}
+}
+
+func TestFlags_StdinFormatSetsStdin(t *testing.T) {
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	var f jty.Flags
+	f.AddToFlagSet(fs)
+	if err := fs.Parse([]string{"--stdin-format=jsonl"}); err != nil {
+		t.Fatal(err)
+	}
+	f.FinishParse("")
+
+	if !f.FromStdin {
+		t.Fatal("expected --stdin-format=jsonl to set FromStdin, but it didn't")
+	}
+}
@@ -0,0 +1,424 @@
+package jty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Output formats, for Job.Format and Flags.Format.
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatINI  = "ini"
+)
+
+// checkFormat returns an error if format is not empty or one of the supported output formats.
+func checkFormat(format string) error {
+	switch format {
+	case "", FormatYAML, FormatTOML, FormatINI:
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// outputFormat returns the format to write req in:
+// req.Format if set, or else the format implied by the extension of req.OutPath,
+// defaulting to YAML.
+func outputFormat(req writeRequest) string {
+	if req.Format != "" {
+		return req.Format
+	}
+
+	switch strings.ToLower(filepath.Ext(req.OutPath)) {
+	case ".toml":
+		return FormatTOML
+	case ".ini":
+		return FormatINI
+	default:
+		return FormatYAML
+	}
+}
+
+// encodeFunc writes docs to w.
+// name is only used to identify the destination in error messages.
+type encodeFunc func(w io.Writer, name string, docs []interface{}) error
+
+// encoderFor returns the function that encodes documents in format.
+func encoderFor(format string) (encodeFunc, error) {
+	switch format {
+	case FormatYAML:
+		return encodeYAML, nil
+	case FormatTOML:
+		return encodeTOML, nil
+	case FormatINI:
+		return encodeINI, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// singleObject returns the only document in docs, which must be an object,
+// for formats that can't hold a stream of documents.
+func singleObject(format, name string, docs []interface{}) (map[string]interface{}, error) {
+	if len(docs) != 1 {
+		return nil, fmt.Errorf("%s output must have exactly one document, but %s has %d", format, name, len(docs))
+	}
+	m, ok := docs[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s output must be an object, but %s is %s", format, name, jsonTypeName(docs[0]))
+	}
+	return m, nil
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case jsonNumber, json.Number:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// formatJSONNumber formats f the way it would appear in JSON,
+// without an exponent for integers that float64 represents exactly.
+func formatJSONNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeTOML writes the single object document in docs to w as TOML.
+func encodeTOML(w io.Writer, name string, docs []interface{}) error {
+	m, err := singleObject("TOML", name, docs)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, nil, m, false); err != nil {
+		return fmt.Errorf("error encoding TOML when writing %s: %v", name, err)
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// tomlNumber returns n formatted as a TOML number.
+// TOML integers are 64-bit, so a larger integer is written as a float if that is exact,
+// and is an error otherwise.
+func tomlNumber(path []string, n jsonNumber) (string, error) {
+	if !n.isInteger() {
+		return string(n), nil
+	}
+	if _, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return string(n), nil
+	}
+
+	if f := n.float64(); !math.IsInf(f, 0) {
+		if i, acc := big.NewFloat(f).Int(nil); acc == big.Exact && i.Cmp(n.bigInt()) == 0 {
+			return strconv.FormatFloat(f, 'e', -1, 64), nil
+		}
+	}
+	return "", fmt.Errorf("integer %s at %s cannot be represented exactly in TOML", n, strings.Join(path, "."))
+}
+
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if bareTOMLKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+// tomlString returns s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isTOMLTableArray reports whether v is a non-empty array of objects,
+// which is written as an array of tables.
+func isTOMLTableArray(v interface{}) bool {
+	a, ok := v.([]interface{})
+	if !ok || len(a) == 0 {
+		return false
+	}
+	for _, e := range a {
+		if _, ok := e.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTOMLTable writes the table m found at path.
+// Its header is written if it has any plain keys, if it is empty, or if it is an element of an array of tables.
+func writeTOMLTable(buf *bytes.Buffer, path []string, m map[string]interface{}, inArray bool) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var plain, tables, tableArrays []string
+	for _, k := range keys {
+		v := m[k]
+		_, isTable := v.(map[string]interface{})
+		switch {
+		case isTable:
+			tables = append(tables, k)
+		case isTOMLTableArray(v):
+			tableArrays = append(tableArrays, k)
+		default:
+			plain = append(plain, k)
+		}
+	}
+
+	if len(path) > 0 {
+		switch {
+		case inArray:
+			fmt.Fprintf(buf, "[[%s]]\n", tomlPath(path))
+		case len(plain) > 0 || len(keys) == 0:
+			fmt.Fprintf(buf, "[%s]\n", tomlPath(path))
+		}
+	}
+
+	for _, k := range plain {
+		keyPath := append(path[:len(path):len(path)], k)
+		v, err := tomlInlineValue(keyPath, m[k])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(k), v)
+	}
+
+	for _, k := range tables {
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		keyPath := append(path[:len(path):len(path)], k)
+		if err := writeTOMLTable(buf, keyPath, m[k].(map[string]interface{}), false); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range tableArrays {
+		keyPath := append(path[:len(path):len(path)], k)
+		for _, e := range m[k].([]interface{}) {
+			if buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			if err := writeTOMLTable(buf, keyPath, e.(map[string]interface{}), true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// tomlInlineValue returns v formatted as a TOML value on a single line.
+func tomlInlineValue(path []string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf("null at %s cannot be represented in TOML", strings.Join(path, "."))
+	case bool:
+		return strconv.FormatBool(v), nil
+	case jsonNumber:
+		return tomlNumber(path, v)
+	case string:
+		return tomlString(v), nil
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			s, err := tomlInlineValue(append(path[:len(path):len(path)], strconv.Itoa(i)), e)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = s
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fields := make([]string, len(keys))
+		for i, k := range keys {
+			s, err := tomlInlineValue(append(path[:len(path):len(path)], k), v[k])
+			if err != nil {
+				return "", err
+			}
+			fields[i] = tomlKey(k) + " = " + s
+		}
+		if len(fields) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(fields, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("unexpected value of type %T at %s", v, strings.Join(path, "."))
+	}
+}
+
+// encodeINI writes the single object document in docs to w as INI:
+// top-level fields with scalar values form the global section,
+// top-level fields with object values are sections,
+// and arrays of scalars are written as repeated keys.
+func encodeINI(w io.Writer, name string, docs []interface{}) error {
+	m, err := singleObject("INI", name, docs)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeINI(&buf, m); err != nil {
+		return fmt.Errorf("error encoding INI when writing %s: %v", name, err)
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func writeINI(buf *bytes.Buffer, m map[string]interface{}) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sections []string
+	for _, k := range keys {
+		if _, ok := m[k].(map[string]interface{}); ok {
+			sections = append(sections, k)
+			continue
+		}
+		if err := writeINIKey(buf, nil, k, m[k]); err != nil {
+			return err
+		}
+	}
+
+	for _, section := range sections {
+		if strings.ContainsAny(section, "[]\r\n") {
+			return fmt.Errorf("section name %q cannot be represented in INI", section)
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(buf, "[%s]\n", section)
+
+		sm := m[section].(map[string]interface{})
+		sectionKeys := make([]string, 0, len(sm))
+		for k := range sm {
+			sectionKeys = append(sectionKeys, k)
+		}
+		sort.Strings(sectionKeys)
+
+		for _, k := range sectionKeys {
+			if err := writeINIKey(buf, []string{section}, k, sm[k]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeINIKey writes one key, or one line per element if v is an array.
+func writeINIKey(buf *bytes.Buffer, path []string, k string, v interface{}) error {
+	keyPath := strings.Join(append(path[:len(path):len(path)], k), ".")
+	if k == "" || strings.ContainsAny(k, "=[]\r\n") || !iniVerbatim(k) {
+		return fmt.Errorf("key %q cannot be represented in INI", keyPath)
+	}
+
+	values := []interface{}{v}
+	if a, ok := v.([]interface{}); ok {
+		values = a
+	}
+
+	for _, e := range values {
+		var s string
+		switch e := e.(type) {
+		case bool:
+			s = strconv.FormatBool(e)
+		case jsonNumber:
+			s = string(e)
+		case string:
+			if strings.ContainsAny(e, "\r\n") {
+				return fmt.Errorf("multi-line string at %s cannot be represented in INI", keyPath)
+			}
+			if !iniVerbatim(e) {
+				return fmt.Errorf("string %q at %s cannot be represented in INI", e, keyPath)
+			}
+			s = e
+		default:
+			return fmt.Errorf("%s at %s cannot be represented in INI", jsonTypeName(e), keyPath)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", k, s)
+	}
+
+	return nil
+}
+
+// iniVerbatim reports whether s reads back unchanged when written unquoted in INI:
+// readers trim surrounding whitespace, and treat a leading ";" or "#" as a comment.
+// INI has no quoting that readers agree on, so other strings are rejected.
+func iniVerbatim(s string) bool {
+	if s != strings.TrimSpace(s) {
+		return false
+	}
+	return !strings.HasPrefix(s, ";") && !strings.HasPrefix(s, "#")
+}
@@ -0,0 +1,200 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func TestProcessor_TOML(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	JY{
+		J: `[{
+  title: 'example',
+  'odd key': 'a "quoted"\nvalue',
+  ports: [80, 443],
+  ratio: 0.5,
+  enabled: true,
+  owner: {name: 'Tom', meta: {tags: ['a']}},
+  empty: {},
+  inline: [{a: 1}, 2],
+  servers: [{name: 'alpha', dc: {id: 1}}, {name: 'beta'}],
+}]`,
+		Y: `enabled = true
+inline = [{ a = 1 }, 2]
+"odd key" = "a \"quoted\"\nvalue"
+ports = [80, 443]
+ratio = 0.5
+title = "example"
+
+[empty]
+
+[owner]
+name = "Tom"
+
+[owner.meta]
+tags = ["a"]
+
+[[servers]]
+name = "alpha"
+
+[servers.dc]
+id = 1
+
+[[servers]]
+name = "beta"
+`,
+	}.runFormat(t, fs, p, log, "out.toml")
+}
+
+func TestProcessor_INI(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	JY{
+		J: `[{
+  main: {debug: false, retries: 3},
+  name: 'global',
+  servers: {host: ['a', 'b']},
+}]`,
+		Y: `name = global
+
+[main]
+debug = false
+retries = 3
+
+[servers]
+host = a
+host = b
+`,
+	}.runFormat(t, fs, p, log, "out.ini")
+}
+
+func TestProcessor_FormatOverridesExtension(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.Mark = true
+
+	JY{J: `[{a: 1}]`}.WriteJ(t, fs, "in.jsonnet")
+
+	p.ProcessJob(jty.Job{InPath: "in.jsonnet", OutPath: "out.conf", Format: jty.FormatTOML})
+	p.ProcessJob(jty.Job{InPath: "in.jsonnet", OutPath: "out.toml", Format: jty.FormatYAML})
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Fatalf("expected empty log, got %q", got)
+	}
+
+	JY{Y: jty.GeneratedMarker + "\na = 1\n"}.ExpectY(t, fs, "out.conf")
+	JY{Y: jty.GeneratedMarker + "\n---\na: 1\n...\n"}.ExpectY(t, fs, "out.toml")
+}
+
+func TestProcessor_FormatShapeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, j, out, wantErr string
+	}{
+		{name: "toml multiple documents", j: `[{a: 1}, {b: 2}]`, out: "out.toml", wantErr: "TOML output must have exactly one document, but out.toml has 2"},
+		{name: "toml non-object", j: `[[1, 2]]`, out: "out.toml", wantErr: "TOML output must be an object, but out.toml is an array"},
+		{name: "toml null", j: `[{a: {b: null}}]`, out: "out.toml", wantErr: "null at a.b cannot be represented in TOML"},
+		{name: "ini nested section", j: `[{s: {t: {u: 1}}}]`, out: "out.ini", wantErr: "an object at s.t cannot be represented in INI"},
+		{name: "ini multi-line", j: `[{a: 'x\ny'}]`, out: "out.ini", wantErr: "multi-line string at a cannot be represented in INI"},
+		{name: "ini padded value", j: `[{a: ' x'}]`, out: "out.ini", wantErr: `string " x" at a cannot be represented in INI`},
+		{name: "ini comment value", j: `[{s: {a: '; x'}}]`, out: "out.ini", wantErr: `string "; x" at s.a cannot be represented in INI`},
+		{name: "ini comment key", j: `[{'#a': 1}]`, out: "out.ini", wantErr: `key "#a" cannot be represented in INI`},
+		{name: "ini array of objects", j: `[{a: [{}]}]`, out: "out.ini", wantErr: "an object at a cannot be represented in INI"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			log := new(bytes.Buffer)
+			p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+			JY{J: tc.j}.WriteJ(t, fs, "in.jsonnet")
+
+			p.Process("in.jsonnet", tc.out)
+			p.Close()
+
+			if got := log.String(); !strings.Contains(got, tc.wantErr) {
+				t.Fatalf("expected log to contain %q, got %q", tc.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestProcessor_FormatShapeErrors_KeepOutput(t *testing.T) {
+	for _, out := range []string{"out.toml", "out.ini"} {
+		out := out
+		t.Run(out, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, new(bytes.Buffer))
+
+			JY{J: `[{a: {b: {c: null}}}]`}.WriteJ(t, fs, "in.jsonnet")
+			const existing = "a = 1\n"
+			if err := afero.WriteFile(fs, out, []byte(existing), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			p.Process("in.jsonnet", out)
+			p.Close()
+
+			if !p.Failed() {
+				t.Fatal("expected failure")
+			}
+			got, err := afero.ReadFile(fs, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != existing {
+				t.Fatalf("expected %s to be left as %q, got %q", out, existing, got)
+			}
+		})
+	}
+}
+
+func TestCommand_FormatFlag(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `[{a: 1}]`}.WriteJ(t, tc.FS, "in.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "-"}, Format: jty.FormatINI}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tc.Stdout.String(), "a = 1\n"; got != want {
+		t.Fatalf("expected stdout %q, got %q", want, got)
+	}
+}
+
+func TestCommand_InvalidFormat(t *testing.T) {
+	tc := NewTestCommand("")
+
+	err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, Format: "xml"})
+	if err == nil || !strings.Contains(err.Error(), `unsupported format "xml"`) {
+		t.Fatalf("expected unsupported format error, got %v", err)
+	}
+}
+
+// runFormat writes jy.J to in.jsonnet, processes it to out, and expects jy.Y there.
+func (jy JY) runFormat(t *testing.T, fs afero.Fs, p *jty.Processor, log *bytes.Buffer, out string) {
+	t.Helper()
+
+	jy.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", out)
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Fatalf("expected empty log, got %q", got)
+	}
+
+	jy.ExpectY(t, fs, out)
+}
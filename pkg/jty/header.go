@@ -0,0 +1,51 @@
+package jty
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// HeaderData is the data available to a Processor's Header template.
+type HeaderData struct {
+	// The path of the Jsonnet source, as given to Process.
+	InPath string
+
+	// The path of the output file, as given to Process.
+	OutPath string
+}
+
+// ParseHeader parses text as a template for the header comment of output files.
+// The template is executed with a HeaderData value.
+func ParseHeader(text string) (*template.Template, error) {
+	return template.New("header").Parse(text)
+}
+
+// writeHeader executes tmpl for the given request and writes the result to w as YAML comment lines.
+// Every line of the result is prefixed with "# ", so the template doesn't need to produce comment syntax.
+func writeHeader(w io.Writer, tmpl *template.Template, req writeRequest) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, HeaderData{InPath: req.InPath, OutPath: req.OutPath}); err != nil {
+		return err
+	}
+
+	text := strings.TrimRight(buf.String(), "\n")
+	if text == "" {
+		return nil
+	}
+
+	var out strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			out.WriteString("#\n")
+			continue
+		}
+		out.WriteString("# ")
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,114 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func TestProcessor_Header(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	header, err := jty.ParseHeader("Generated from {{.InPath}} into {{.OutPath}}.\n\nDo not edit.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Header = header
+	p.Mark = true
+
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+
+	p.Process("in1.jsonnet", "out1.yml")
+	p.Close()
+
+	JY{Y: jty.GeneratedMarker + `
+# Generated from in1.jsonnet into out1.yml.
+#
+# Do not edit.
+` + JYOneTwo.Y}.ExpectY(t, fs, "out1.yml")
+
+	if got := log.String(); got != "" {
+		t.Errorf("expected empty log, got %q", got)
+	}
+}
+
+func TestProcessor_Header_ExecuteError(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	header, err := jty.ParseHeader("{{.NoSuchField}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Header = header
+
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+
+	p.Process("in1.jsonnet", "out1.yml")
+	p.Close()
+
+	want := "error writing header when writing out1.yml"
+	if got := log.String(); !strings.Contains(got, want) {
+		t.Errorf("expected log %q to contain %q but it didn't", got, want)
+	}
+}
+
+func TestCommand_Header_Invalid(t *testing.T) {
+	tc := NewTestCommand("")
+
+	err := tc.Cmd.Run(&jty.Flags{
+		Args:   []string{"in1.jsonnet", "out1.yml"},
+		Header: "{{.InPath",
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid header template: ") {
+		t.Fatalf("expected invalid header template error, got %v", err)
+	}
+}
+
+func TestProcessor_Header_ExecuteError_KeepsOutput(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, new(bytes.Buffer))
+
+	header, err := jty.ParseHeader("{{.NoSuchField}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Header = header
+
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+	if err := afero.WriteFile(fs, "out1.yml", []byte(JYSeq.Y), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Process("in1.jsonnet", "out1.yml")
+	p.Close()
+
+	if !p.Failed() {
+		t.Fatal("expected failure")
+	}
+	JYSeq.ExpectY(t, fs, "out1.yml")
+}
+
+func TestCommand_Header_ExecuteError(t *testing.T) {
+	tc := NewTestCommand("")
+	JYOneTwo.WriteJ(t, tc.FS, "in1.jsonnet")
+
+	err := tc.Cmd.Run(&jty.Flags{
+		Args:   []string{"in1.jsonnet", "out1.yml"},
+		Header: "{{.Nope}}",
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid header template: ") {
+		t.Fatalf("expected invalid header template error, got %v", err)
+	}
+	if exists, _ := afero.Exists(tc.FS, "out1.yml"); exists {
+		t.Fatal("expected out1.yml not to be written")
+	}
+}
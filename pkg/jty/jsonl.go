@@ -0,0 +1,115 @@
+package jty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonlJob is the JSON representation of a Job on stdin with --stdin-format=jsonl.
+type jsonlJob struct {
+	In  string `json:"in"`
+	Out string `json:"out"`
+
+	// String values are bound as strings; any other JSON value is bound as code.
+	TLA map[string]json.RawMessage `json:"tla"`
+	Ext map[string]json.RawMessage `json:"ext"`
+
+	Format string `json:"format"`
+
+	// Octal permissions of the output file, like "0644".
+	Mode string `json:"mode"`
+}
+
+// maxJSONLLine is the longest line accepted with --stdin-format=jsonl.
+const maxJSONLLine = 16 * 1024 * 1024
+
+// readStdinJSONL reads one job per line from c.Stdin.
+// Blank lines are ignored.
+func (c *Command) readStdinJSONL() ([]Job, error) {
+	s := bufio.NewScanner(c.Stdin)
+	s.Buffer(nil, maxJSONLLine)
+
+	var jobs []Job
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		j, err := parseJSONLJob(line)
+		if err != nil {
+			return nil, fmt.Errorf("stdin line %d: %v", lineNum, err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %v", err)
+	}
+
+	if len(jobs) == 0 {
+		return nil, ErrNoInputFiles
+	}
+
+	return jobs, nil
+}
+
+func parseJSONLJob(line []byte) (Job, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+
+	var jj jsonlJob
+	if err := dec.Decode(&jj); err != nil {
+		return Job{}, fmt.Errorf("invalid job: %v", err)
+	}
+	if dec.More() {
+		return Job{}, fmt.Errorf("invalid job: unexpected data after JSON object")
+	}
+
+	if jj.In == "" {
+		return Job{}, fmt.Errorf(`missing "in"`)
+	}
+	if jj.Out == "" {
+		return Job{}, fmt.Errorf(`missing "out"`)
+	}
+	if err := checkFormat(jj.Format); err != nil {
+		return Job{}, err
+	}
+
+	j := Job{InPath: jj.In, OutPath: jj.Out, Format: jj.Format}
+	if jj.Mode != "" {
+		mode, err := parseMode(jj.Mode)
+		if err != nil {
+			return Job{}, fmt.Errorf(`invalid "mode": %v`, err)
+		}
+		j.Mode = mode
+	}
+
+	j.TLAVars, j.TLACode = splitBindings(jj.TLA)
+	j.ExtVars, j.ExtCode = splitBindings(jj.Ext)
+
+	return j, nil
+}
+
+// splitBindings separates the string values in raw from the other values,
+// which are kept as their JSON encoding, which is also valid Jsonnet code.
+func splitBindings(raw map[string]json.RawMessage) (vars, code map[string]string) {
+	for k, v := range raw {
+		var str string
+		if err := json.Unmarshal(v, &str); err == nil {
+			if vars == nil {
+				vars = make(map[string]string)
+			}
+			vars[k] = str
+			continue
+		}
+
+		if code == nil {
+			code = make(map[string]string)
+		}
+		code[k] = string(v)
+	}
+
+	return vars, code
+}
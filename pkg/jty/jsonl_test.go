@@ -0,0 +1,100 @@
+package jty_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jty"
+)
+
+func TestCommand_StdinJSONL(t *testing.T) {
+	stdin := strings.Join([]string{
+		`{"in": "in1.jsonnet", "out": "out1.yml"}`,
+		``,
+		`{"in": "vars.jsonnet", "out": "dev.yml", "ext": {"env": "dev"}, "tla": {"replicas": 1}}`,
+		`{"in": "vars.jsonnet", "out": "prod.yml", "ext": {"env": "prod"}, "tla": {"replicas": 3}, "format": "yaml"}`,
+		``,
+	}, "\n")
+
+	tc := NewTestCommand(stdin)
+	JYOneTwo.WriteJ(t, tc.FS, "in1.jsonnet")
+	JY{J: `function(replicas) [{env: std.extVar('env'), replicas: replicas}]`}.WriteJ(t, tc.FS, "vars.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		FromStdin:   true,
+		StdinFormat: jty.StdinFormatJSONL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	JYOneTwo.ExpectY(t, tc.FS, "out1.yml")
+	JY{Y: "---\nenv: dev\nreplicas: 1\n...\n"}.ExpectY(t, tc.FS, "dev.yml")
+	JY{Y: "---\nenv: prod\nreplicas: 3\n...\n"}.ExpectY(t, tc.FS, "prod.yml")
+
+	if tc.Stderr.String() != "" {
+		t.Fatalf("expected no standard error, got %q", tc.Stderr.String())
+	}
+}
+
+func TestCommand_StdinJSONL_Errors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		stdin string
+		want  string
+	}{
+		"malformed": {
+			stdin: `{"in": "a.jsonnet", "out": "a.yml"}` + "\n" + `{"in": ` + "\n",
+			want:  "stdin line 2: invalid job: unexpected EOF",
+		},
+		"unknown field": {
+			stdin: "\n" + `{"in": "a.jsonnet", "out": "a.yml", "outt": "b.yml"}` + "\n",
+			want:  `stdin line 2: invalid job: json: unknown field "outt"`,
+		},
+		"missing out": {
+			stdin: `{"in": "a.jsonnet"}` + "\n",
+			want:  `stdin line 1: missing "out"`,
+		},
+		"bad format": {
+			stdin: `{"in": "a.jsonnet", "out": "a.yml", "format": "xml"}` + "\n",
+			want:  `stdin line 1: unsupported format "xml"`,
+		},
+		"bad mode": {
+			stdin: `{"in": "a.jsonnet", "out": "a.yml", "mode": "0999"}` + "\n",
+			want:  `stdin line 1: invalid "mode": "0999" is not an octal permission like 0644`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc := NewTestCommand(tt.stdin)
+
+			err := tc.Cmd.Run(&jty.Flags{
+				FromStdin:   true,
+				StdinFormat: jty.StdinFormatJSONL,
+			})
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCommand_StdinJSONL_Empty(t *testing.T) {
+	tc := NewTestCommand("\n")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		FromStdin:   true,
+		StdinFormat: jty.StdinFormatJSONL,
+	}); err != jty.ErrNoInputFiles {
+		t.Fatalf("expected ErrNoInputFiles, got %v", err)
+	}
+}
+
+func TestCommand_StdinJSONL_Zero(t *testing.T) {
+	tc := NewTestCommand("")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		FromStdin:   true,
+		Zero:        true,
+		StdinFormat: jty.StdinFormatJSONL,
+	}); err != jty.ErrZeroWithJSONL {
+		t.Fatalf("expected ErrZeroWithJSONL, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package jty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/formatter"
+	"github.com/spf13/afero"
+)
+
+// JsonnetFormatter checks Jsonnet files against the style of go-jsonnet's formatter,
+// the same as jsonnetfmt, or rewrites them in that style.
+// Each file is handled at most once, however many times it is read.
+type JsonnetFormatter struct {
+	fix bool
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// NewJsonnetFormatter returns a JsonnetFormatter that reports unformatted files,
+// or rewrites them in place if fix is true.
+func NewJsonnetFormatter(fix bool) *JsonnetFormatter {
+	return &JsonnetFormatter{
+		fix:  fix,
+		seen: make(map[string]struct{}),
+	}
+}
+
+// handle checks or fixes the formatting of content, which was read from path in fs.
+// It reports whether it rewrote the file,
+// and returns an error if the file is unformatted and f is only checking.
+// Files that can't be parsed are ignored, because evaluating them reports a better error.
+func (f *JsonnetFormatter) handle(fs afero.Fs, path, content string) (fixed bool, err error) {
+	key := canonicalPath(fs, path)
+	f.mu.Lock()
+	_, seen := f.seen[key]
+	f.seen[key] = struct{}{}
+	f.mu.Unlock()
+	if seen {
+		return false, nil
+	}
+
+	formatted, err := formatter.Format(path, content, formatter.DefaultOptions())
+	if err != nil || formatted == content {
+		return false, nil
+	}
+
+	if !f.fix {
+		return false, fmt.Errorf("%s is not formatted; run jty --fmt to fix it", path)
+	}
+
+	perm := fsPerm(fs, path)
+	if err := afero.WriteFile(fs, path, []byte(formatted), perm); err != nil {
+		return false, fmt.Errorf("failed to reformat %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// fsPerm returns the permissions of the file at path, or 0666 if they can't be determined.
+func fsPerm(fs afero.Fs, path string) os.FileMode {
+	fi, err := fs.Stat(path)
+	if err != nil {
+		return 0666
+	}
+	return fi.Mode().Perm()
+}
+
+// jsonnetFmtImporter checks or fixes the formatting of imported Jsonnet files,
+// except for those in library search paths, which usually hold vendored code.
+type jsonnetFmtImporter struct {
+	jsonnet.Importer
+
+	f *JsonnetFormatter
+
+	// Canonical library search paths.
+	libRoots []string
+
+	// Receives problems and fixes. Must be set before the first import.
+	p *Processor
+}
+
+func newJsonnetFmtImporter(imp jsonnet.Importer, f *JsonnetFormatter, jpaths []string) *jsonnetFmtImporter {
+	libRoots := make([]string, len(jpaths))
+	for i, r := range jpaths {
+		// The wrapped importer always reads from the OS filesystem.
+		libRoots[i] = canonicalPath(osFs, r)
+	}
+
+	return &jsonnetFmtImporter{Importer: imp, f: f, libRoots: libRoots}
+}
+
+func (i *jsonnetFmtImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	contents, foundAt, err := i.Importer.Import(importedFrom, importedPath)
+	if err != nil {
+		return contents, foundAt, err
+	}
+
+	// Only Jsonnet source is formatted; importstr may read any kind of file.
+	switch filepath.Ext(foundAt) {
+	case ".jsonnet", ".libsonnet":
+	default:
+		return contents, foundAt, nil
+	}
+	if withinRoots(canonicalPath(osFs, foundAt), i.libRoots) {
+		return contents, foundAt, nil
+	}
+
+	fixed, err := i.f.handle(osFs, foundAt, contents.String())
+	if err != nil {
+		// Imports are only read while evaluating, so blame the job being evaluated.
+		i.p.fail(i.p.evaluating, err)
+	} else if fixed && i.p.Verbose {
+		i.p.logf("reformatted %s", foundAt)
+	}
+
+	return contents, foundAt, nil
+}
@@ -0,0 +1,133 @@
+package jty_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+const (
+	unformattedJsonnet = "[{a:1}]"
+	formattedJsonnet   = "[{ a: 1 }]\n"
+)
+
+func TestProcessor_JsonnetFormatter_Check(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.JsonnetFormatter = jty.NewJsonnetFormatter(false)
+
+	JY{J: unformattedJsonnet}.WriteJ(t, fs, "bad.jsonnet")
+	JY{J: formattedJsonnet}.WriteJ(t, fs, "good.jsonnet")
+
+	// The same input twice is only reported once.
+	p.Process("bad.jsonnet", "bad1.yml")
+	p.Process("bad.jsonnet", "bad2.yml")
+	p.Process("good.jsonnet", "good.yml")
+	p.Close()
+
+	want := "bad.jsonnet is not formatted; run jty --fmt to fix it\n"
+	if got := log.String(); got != want {
+		t.Fatalf("expected log %q, got %q", want, got)
+	}
+
+	// Unformatted inputs are left alone, and still evaluated.
+	JY{Y: unformattedJsonnet}.ExpectY(t, fs, "bad.jsonnet")
+	JY{Y: "---\na: 1\n...\n"}.ExpectY(t, fs, "bad1.yml")
+}
+
+func TestProcessor_JsonnetFormatter_Fix(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.JsonnetFormatter = jty.NewJsonnetFormatter(true)
+	p.Verbose = true
+
+	JY{J: unformattedJsonnet}.WriteJ(t, fs, "bad.jsonnet")
+
+	p.Process("bad.jsonnet", "bad.yml")
+	p.Close()
+
+	if got := log.String(); !strings.HasPrefix(got, "reformatted bad.jsonnet\n") {
+		t.Fatalf("expected log to report reformatting, got %q", got)
+	}
+
+	JY{Y: formattedJsonnet}.ExpectY(t, fs, "bad.jsonnet")
+	JY{Y: "---\na: 1\n...\n"}.ExpectY(t, fs, "bad.yml")
+}
+
+func TestCommand_FmtImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jty-fmt-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vendor := filepath.Join(dir, "vendor")
+	if err := os.Mkdir(vendor, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(dir, "lib.libsonnet"):         "{a:1}",
+		filepath.Join(dir, "data.txt"):              "{a:1}",
+		filepath.Join(vendor, "vendored.libsonnet"): "{b:2}",
+	} {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tc := NewTestCommand("")
+	JY{J: `[
+  (import '` + filepath.Join(dir, "lib.libsonnet") + `')
+  + (import 'vendored.libsonnet')
+  + { c: importstr '` + filepath.Join(dir, "data.txt") + `' },
+]
+`}.WriteJ(t, tc.FS, "in.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args:       []string{"in.jsonnet", "out.yml"},
+		Fmt:        true,
+		FmtImports: true,
+		JPaths:     []string{vendor},
+	}); err != nil {
+		t.Fatalf("unexpected error %v: %s", err, tc.Stderr.String())
+	}
+
+	for path, want := range map[string]string{
+		filepath.Join(dir, "lib.libsonnet"):         "{ a: 1 }\n",
+		filepath.Join(dir, "data.txt"):              "{a:1}",
+		filepath.Join(vendor, "vendored.libsonnet"): "{b:2}",
+	} {
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("expected %s to contain %q, got %q", path, want, got)
+		}
+	}
+}
+
+func TestCommand_FmtFlagErrors(t *testing.T) {
+	for _, tc := range []struct {
+		flags jty.Flags
+		want  error
+	}{
+		{flags: jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, Fmt: true, FmtCheck: true}, want: jty.ErrFmtAndFmtCheck},
+		{flags: jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, FmtImports: true}, want: jty.ErrFmtImportsAlone},
+	} {
+		cmd := NewTestCommand("")
+		if err := cmd.Cmd.Run(&tc.flags); err != tc.want {
+			t.Errorf("expected %v, got %v", tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package jty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/spf13/afero"
+)
+
+// Values for TestFlags.Format.
+const (
+	TestFormatTAP   = "tap"
+	TestFormatJUnit = "junit"
+)
+
+// TestFileSuffix identifies the Jsonnet files that jty test runs.
+const TestFileSuffix = "_test.jsonnet"
+
+var (
+	ErrNoTests     = errors.New("no " + TestFileSuffix + " files found")
+	ErrTestsFailed = errors.New("some Jsonnet tests failed")
+)
+
+// jsonnetTestResult is the outcome of one test case in a test file.
+type jsonnetTestResult struct {
+	path string
+
+	// The name of the test case within the file, or empty if the whole file is one test.
+	name string
+
+	// Why the test failed, or empty if it passed.
+	failure string
+
+	elapsed time.Duration
+}
+
+func (r jsonnetTestResult) String() string {
+	if r.name == "" {
+		return r.path
+	}
+	return r.path + ": " + r.name
+}
+
+// Test evaluates every test file named by f.Args, or found in directories named by f.Args,
+// and reports the results to c.Stdout.
+//
+// A test file fails if evaluating it fails, for example because of a failed assert.
+// If it evaluates to an object with a boolean pass field, it fails if pass is false,
+// with the message field describing the failure.
+// If it evaluates to an object whose fields are all such objects, each field is a separate test case.
+// Any other value passes.
+func (c *Command) Test(f *TestFlags) error {
+	switch f.Format {
+	case "", TestFormatTAP, TestFormatJUnit:
+	default:
+		return fmt.Errorf("unknown test report format %q", f.Format)
+	}
+
+	args := f.Args
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	paths, err := findTestFiles(c.FS, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return ErrNoTests
+	}
+
+	// One VM for every test file, so that shared imports are only parsed once.
+	vm := jsonnet.MakeVM()
+	vm.Importer(&jsonnet.FileImporter{JPaths: f.JPaths})
+	for _, nf := range c.natives(f.Natives, nil) {
+		vm.NativeFunction(nf)
+	}
+
+	var results []jsonnetTestResult
+	for _, path := range paths {
+		results = append(results, runJsonnetTest(vm, c.FS, path)...)
+	}
+
+	if f.Format == TestFormatJUnit {
+		err = writeTestJUnit(c.Stdout, results)
+	} else {
+		err = writeTestTAP(c.Stdout, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.failure != "" {
+			return ErrTestsFailed
+		}
+	}
+	return nil
+}
+
+// findTestFiles returns the test files named by args:
+// files are used as given, and directories are searched recursively for TestFileSuffix files,
+// skipping hidden directories.
+func findTestFiles(fs afero.Fs, args []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	for _, arg := range args {
+		fi, err := fs.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			add(arg)
+			continue
+		}
+
+		err = afero.Walk(fs, arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if path != arg && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, TestFileSuffix) {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+// runJsonnetTest evaluates the test file at path and returns the result of each of its test cases.
+func runJsonnetTest(vm *jsonnet.VM, fs afero.Fs, path string) []jsonnetTestResult {
+	start := time.Now()
+	fail := func(format string, args ...interface{}) []jsonnetTestResult {
+		return []jsonnetTestResult{{path: path, failure: fmt.Sprintf(format, args...), elapsed: time.Since(start)}}
+	}
+
+	content, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return fail("failed to read %s: %v", path, err)
+	}
+
+	out, err := vm.EvaluateSnippet(path, string(content))
+	if err != nil {
+		// Jsonnet's stack traces pad their lines with trailing tabs.
+		lines := strings.Split(strings.TrimRight(err.Error(), "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+		return fail("%s", strings.Join(lines, "\n"))
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		return fail("error unmarshaling result: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if failure, ok := testCaseFailure(v); ok {
+		return []jsonnetTestResult{{path: path, failure: failure, elapsed: elapsed}}
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return []jsonnetTestResult{{path: path, elapsed: elapsed}}
+	}
+	// Fields that aren't test cases, such as shared fixtures, are ignored.
+	names := make([]string, 0, len(m))
+	for name, c := range m {
+		if _, ok := testCaseFailure(c); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return []jsonnetTestResult{{path: path, elapsed: elapsed}}
+	}
+	sort.Strings(names)
+
+	// There's no way to time the cases separately, so share the file's time between them.
+	each := elapsed / time.Duration(len(names))
+	results := make([]jsonnetTestResult, len(names))
+	for i, name := range names {
+		failure, _ := testCaseFailure(m[name])
+		results[i] = jsonnetTestResult{path: path, name: name, failure: failure, elapsed: each}
+	}
+	return results
+}
+
+// testCaseFailure reports whether v is a test case, an object with a boolean pass field,
+// and if so, why it failed, or the empty string if it passed.
+func testCaseFailure(v interface{}) (failure string, isCase bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	pass, ok := m["pass"].(bool)
+	if !ok {
+		return "", false
+	}
+	if pass {
+		return "", true
+	}
+
+	switch msg := m["message"].(type) {
+	case nil:
+		return "pass is false", true
+	case string:
+		return msg, true
+	default:
+		j, _ := json.Marshal(msg)
+		return string(j), true
+	}
+}
+
+// writeTestTAP writes results to w in the Test Anything Protocol, version 13.
+func writeTestTAP(w io.Writer, results []jsonnetTestResult) error {
+	var sb strings.Builder
+	sb.WriteString("TAP version 13\n")
+	fmt.Fprintf(&sb, "1..%d\n", len(results))
+	for i, r := range results {
+		if r.failure == "" {
+			fmt.Fprintf(&sb, "ok %d - %s\n", i+1, r)
+			continue
+		}
+
+		fmt.Fprintf(&sb, "not ok %d - %s\n", i+1, r)
+		sb.WriteString("  ---\n  message: |\n")
+		for _, line := range strings.Split(r.failure, "\n") {
+			sb.WriteString("    " + line + "\n")
+		}
+		sb.WriteString("  ...\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeTestJUnit writes results to w as JUnit XML, with a test suite for each file.
+func writeTestJUnit(w io.Writer, results []jsonnetTestResult) error {
+	var suites []junitTestSuite
+	for i := 0; i < len(results); {
+		path := results[i].path
+
+		var cases []junitTestCase
+		var elapsed time.Duration
+		for ; i < len(results) && results[i].path == path; i++ {
+			r := results[i]
+			name := r.name
+			if name == "" {
+				name = filepath.Base(path)
+			}
+			c := junitTestCase{Name: name, ClassName: path, Time: junitSeconds(r.elapsed)}
+			if r.failure != "" {
+				c.Failure = &junitFailure{Message: firstLine(r.failure), Text: r.failure}
+			}
+			cases = append(cases, c)
+			elapsed += r.elapsed
+		}
+
+		suites = append(suites, newJUnitTestSuite(path, cases, elapsed))
+	}
+
+	return writeJUnit(w, suites...)
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+package jty_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jty"
+)
+
+// writeJsonnetTests writes a set of test files to tc.FS:
+// one passing, one failing by assert, and one with named cases.
+func writeJsonnetTests(t *testing.T, tc *TestCommand) {
+	t.Helper()
+
+	for path, code := range map[string]string{
+		"lib/a_test.jsonnet":         `assert 1 + 1 == 2; {}`,
+		"lib/b_test.jsonnet":         `assert std.length('abc') == 4 : 'length is wrong'; {}`,
+		"lib/cases_test.jsonnet":     `{ adds: { pass: 1 + 1 == 2 }, subtracts: { pass: 2 - 1 == 0, message: 'expected 1' } }`,
+		"lib/helper.jsonnet":         `error 'not a test'`,
+		"lib/.hidden/x_test.jsonnet": `error 'hidden'`,
+	} {
+		JY{J: code}.WriteJ(t, tc.FS, path)
+	}
+}
+
+func TestCommand_Test_TAP(t *testing.T) {
+	tc := NewTestCommand("")
+	writeJsonnetTests(t, tc)
+
+	err := tc.Cmd.Test(&jty.TestFlags{Args: []string{"lib"}})
+	if err != jty.ErrTestsFailed {
+		t.Fatalf("expected ErrTestsFailed, got %v", err)
+	}
+
+	want := `TAP version 13
+1..4
+ok 1 - lib/a_test.jsonnet
+not ok 2 - lib/b_test.jsonnet
+  ---
+  message: |
+    RUNTIME ERROR: length is wrong
+    	lib/b_test.jsonnet:1:1-54
+    	During evaluation
+  ...
+ok 3 - lib/cases_test.jsonnet: adds
+not ok 4 - lib/cases_test.jsonnet: subtracts
+  ---
+  message: |
+    expected 1
+  ...
+`
+	if got := tc.Stdout.String(); got != want {
+		t.Fatalf("expected TAP output:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestCommand_Test_JUnit(t *testing.T) {
+	tc := NewTestCommand("")
+	writeJsonnetTests(t, tc)
+
+	if err := tc.Cmd.Test(&jty.TestFlags{Args: []string{"lib/cases_test.jsonnet", "lib/a_test.jsonnet"}, Format: jty.TestFormatJUnit}); err != jty.ErrTestsFailed {
+		t.Fatalf("expected ErrTestsFailed, got %v", err)
+	}
+
+	var report struct {
+		Suites []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Cases    []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(tc.Stdout.Bytes(), &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, tc.Stdout.String())
+	}
+
+	if len(report.Suites) != 2 {
+		t.Fatalf("expected 2 suites, got %d:\n%s", len(report.Suites), tc.Stdout.String())
+	}
+	cases := report.Suites[0]
+	if cases.Name != "lib/cases_test.jsonnet" || cases.Tests != 2 || cases.Failures != 1 {
+		t.Fatalf("unexpected first suite: %+v", cases)
+	}
+	if c := cases.Cases[1]; c.Name != "subtracts" || c.Failure == nil || c.Failure.Message != "expected 1" {
+		t.Fatalf("unexpected failing case: %+v", c)
+	}
+	if a := report.Suites[1]; a.Name != "lib/a_test.jsonnet" || a.Tests != 1 || a.Failures != 0 || a.Cases[0].Name != "a_test.jsonnet" {
+		t.Fatalf("unexpected second suite: %+v", a)
+	}
+}
+
+func TestCommand_Test_Pass(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `{ pass: true }`}.WriteJ(t, tc.FS, "ok_test.jsonnet")
+
+	if err := tc.Cmd.Test(&jty.TestFlags{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := tc.Stdout.String(); !strings.Contains(got, "ok 1 - ok_test.jsonnet\n") {
+		t.Fatalf("expected passing test, got %q", got)
+	}
+}
+
+func TestCommand_Test_NoTests(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `{}`}.WriteJ(t, tc.FS, "lib/x.jsonnet")
+
+	if err := tc.Cmd.Test(&jty.TestFlags{Args: []string{"lib"}}); err != jty.ErrNoTests {
+		t.Fatalf("expected ErrNoTests, got %v", err)
+	}
+}
+
+func TestCommand_Test_MixedFields(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `{ a: { pass: false, message: 'broken' }, b: { pass: true }, note: 'x' }`}.WriteJ(t, tc.FS, "mixed_test.jsonnet")
+
+	if err := tc.Cmd.Test(&jty.TestFlags{}); err != jty.ErrTestsFailed {
+		t.Fatalf("expected ErrTestsFailed, got %v", err)
+	}
+
+	want := `TAP version 13
+1..2
+not ok 1 - mixed_test.jsonnet: a
+  ---
+  message: |
+    broken
+  ...
+ok 2 - mixed_test.jsonnet: b
+`
+	if got := tc.Stdout.String(); got != want {
+		t.Fatalf("expected TAP output:\n%s\ngot:\n%s", want, got)
+	}
+}
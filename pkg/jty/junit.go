@@ -0,0 +1,127 @@
+package jty
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root element of a JUnit XML report,
+// in the form understood by common CI systems.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSeconds formats d as JUnit expects durations: seconds, with millisecond precision.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// newJUnitTestSuite returns a suite named name containing cases,
+// with its totals filled in.
+func newJUnitTestSuite(name string, cases []junitTestCase, elapsed time.Duration) junitTestSuite {
+	s := junitTestSuite{
+		Name:  name,
+		Tests: len(cases),
+		Time:  junitSeconds(elapsed),
+		Cases: cases,
+	}
+	for _, c := range cases {
+		if c.Failure != nil {
+			s.Failures++
+		}
+	}
+	return s
+}
+
+// writeJUnit writes suites to w as an indented JUnit XML document.
+func writeJUnit(w io.Writer, suites ...junitTestSuite) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: suites}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// jobResult is the outcome of one job given to a Processor with RecordResults set.
+// It is only modified by the goroutine currently handling the job.
+type jobResult struct {
+	Job
+
+	start   time.Time
+	elapsed time.Duration
+
+	// Every error logged for the job, in order.
+	failures []string
+}
+
+// finish records that r's job has been completed.
+// It is a no-op if r is nil, so callers need not check whether results are being recorded.
+func (r *jobResult) finish() {
+	if r == nil {
+		return
+	}
+	r.elapsed = time.Since(r.start)
+}
+
+// WriteJUnit writes a JUnit XML report to w with one test case for each job,
+// failed with the errors logged for that job.
+// It must only be called after Close, and only reports jobs processed while RecordResults was set.
+func (p *Processor) WriteJUnit(w io.Writer) error {
+	// Don't need to take lock, as Close has finished all goroutines which may access the results.
+	var cases []junitTestCase
+	var start, end time.Time
+	for _, r := range p.results {
+		c := junitTestCase{
+			Name:      r.InPath + " -> " + r.OutPath,
+			ClassName: "jty",
+			Time:      junitSeconds(r.elapsed),
+		}
+		if len(r.failures) > 0 {
+			text := strings.Join(r.failures, "\n")
+			c.Failure = &junitFailure{Message: firstLine(text), Text: text}
+		}
+		cases = append(cases, c)
+
+		if start.IsZero() || r.start.Before(start) {
+			start = r.start
+		}
+		if e := r.start.Add(r.elapsed); e.After(end) {
+			end = e
+		}
+	}
+
+	// Jobs are processed concurrently, so the suite's time is the wall time of the run
+	// rather than the sum of its cases' times.
+	return writeJUnit(w, newJUnitTestSuite("jty", cases, end.Sub(start)))
+}
@@ -0,0 +1,120 @@
+package jty_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+type junitReport struct {
+	Suites []struct {
+		Name     string `xml:"name,attr"`
+		Tests    int    `xml:"tests,attr"`
+		Failures int    `xml:"failures,attr"`
+		Time     string `xml:"time,attr"`
+		Cases    []struct {
+			Name    string `xml:"name,attr"`
+			Time    string `xml:"time,attr"`
+			Failure *struct {
+				Message string `xml:"message,attr"`
+				Text    string `xml:",chardata"`
+			} `xml:"failure"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+func TestCommand_JUnit(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `[{a: 1}]`}.WriteJ(t, tc.FS, "good.jsonnet")
+	JY{J: `[{a: error 'boom'}]`}.WriteJ(t, tc.FS, "bad.jsonnet")
+
+	err := tc.Cmd.Run(&jty.Flags{
+		Args:  []string{"good.jsonnet", "good.yml", "bad.jsonnet", "bad.yml", "missing.jsonnet", "missing.yml"},
+		JUnit: "report.xml",
+	})
+	if err != jty.ErrEncounteredErrors {
+		t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+	}
+
+	b, err := afero.ReadFile(tc.FS, "report.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report junitReport
+	if err := xml.Unmarshal(b, &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, b)
+	}
+
+	if len(report.Suites) != 1 {
+		t.Fatalf("expected 1 suite, got %d:\n%s", len(report.Suites), b)
+	}
+	s := report.Suites[0]
+	if s.Tests != 3 || s.Failures != 2 {
+		t.Fatalf("expected 3 tests with 2 failures, got %d with %d:\n%s", s.Tests, s.Failures, b)
+	}
+
+	// Cases are in the order the pairs were given.
+	good, bad, missing := s.Cases[0], s.Cases[1], s.Cases[2]
+	if good.Name != "good.jsonnet -> good.yml" || good.Failure != nil || good.Time == "" {
+		t.Fatalf("unexpected passing case: %+v", good)
+	}
+	if bad.Name != "bad.jsonnet -> bad.yml" || bad.Failure == nil {
+		t.Fatalf("unexpected failing case: %+v", bad)
+	}
+	if !strings.HasPrefix(bad.Failure.Message, "failed to evaluate jsonnet at bad.jsonnet: ") || !strings.Contains(bad.Failure.Text, "boom") {
+		t.Fatalf("unexpected failure: %+v", bad.Failure)
+	}
+	if missing.Failure == nil || !strings.HasPrefix(missing.Failure.Message, "failed to read missing.jsonnet: ") {
+		t.Fatalf("unexpected failing case: %+v", missing)
+	}
+
+	// The failure bodies are the same messages logged to stderr.
+	if !strings.Contains(tc.Stderr.String(), bad.Failure.Message) {
+		t.Fatalf("expected failure %q to be logged, got %q", bad.Failure.Message, tc.Stderr.String())
+	}
+}
+
+func TestCommand_JUnit_NonFatalFailures(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: "[{a:1}]"}.WriteJ(t, tc.FS, "in.jsonnet")
+
+	// An unformatted input is still written, but its pair fails.
+	err := tc.Cmd.Run(&jty.Flags{
+		Args:     []string{"in.jsonnet", "out.yml"},
+		FmtCheck: true,
+		JUnit:    "report.xml",
+	})
+	if err != jty.ErrEncounteredErrors {
+		t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+	}
+	if _, err := tc.FS.Stat("out.yml"); err != nil {
+		t.Fatalf("expected output to be written: %v", err)
+	}
+
+	b, err := afero.ReadFile(tc.FS, "report.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report junitReport
+	if err := xml.Unmarshal(b, &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, b)
+	}
+	if f := report.Suites[0].Cases[0].Failure; f == nil || !strings.Contains(f.Message, "in.jsonnet is not formatted") {
+		t.Fatalf("expected formatting failure, got %s", b)
+	}
+}
+
+func TestCommand_JUnit_DryRun(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `[{a: 1}]`}.WriteJ(t, tc.FS, "in.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, DryRun: true, JUnit: "report.xml"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tc.FS.Stat("report.xml"); err == nil {
+		t.Fatal("expected no report in a dry run")
+	}
+}
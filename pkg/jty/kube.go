@@ -0,0 +1,232 @@
+package jty
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// expandKubeLists replaces every Kubernetes List object in docs with the objects in its items.
+// If comments is set, the "#" comment field of a List is carried to its first item,
+// and any other comment field of a List is an error, because there is nowhere left to write it.
+func expandKubeLists(docs []interface{}, comments bool) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			out = append(out, doc)
+			continue
+		}
+
+		kind, _ := m["kind"].(string)
+		items, ok := m["items"].([]interface{})
+		if !ok || !strings.HasSuffix(kind, "List") {
+			out = append(out, doc)
+			continue
+		}
+
+		if comments {
+			if err := carryKubeListComment(kind, m, items); err != nil {
+				return nil, err
+			}
+		}
+
+		expanded, err := expandKubeLists(items, comments)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+// carryKubeListComment moves the "#" comment field of the List m to the first of its items,
+// ahead of any comment that item already has.
+func carryKubeListComment(kind string, m map[string]interface{}, items []interface{}) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, _, ok := yamlCommentTarget(k); ok && k != yamlHeadCommentPrefix {
+			return fmt.Errorf("%s has comment field %q, which can't be written once its items are expanded", kind, k)
+		}
+	}
+
+	c, ok := m[yamlHeadCommentPrefix].(string)
+	if !ok {
+		// Either there is no comment, or stripping comments reports that it isn't a string.
+		if _, exists := m[yamlHeadCommentPrefix]; exists {
+			return fmt.Errorf("%s: comment field %q must be a string, but is %s", kind, yamlHeadCommentPrefix, jsonTypeName(m[yamlHeadCommentPrefix]))
+		}
+		return nil
+	}
+	var first map[string]interface{}
+	if len(items) > 0 {
+		first, _ = items[0].(map[string]interface{})
+	}
+	if first == nil {
+		return fmt.Errorf("%s has comment field %q, but no object item to carry it", kind, yamlHeadCommentPrefix)
+	}
+
+	if existing, exists := first[yamlHeadCommentPrefix]; exists {
+		s, ok := existing.(string)
+		if !ok {
+			// Stripping comments reports that it isn't a string.
+			return nil
+		}
+		c += "\n" + s
+	}
+	first[yamlHeadCommentPrefix] = c
+	return nil
+}
+
+// kubeMeta is the identifying information of a Kubernetes object.
+type kubeMeta struct {
+	Kind, Namespace, Name string
+}
+
+// getKubeMeta returns the kind, namespace, and name of doc,
+// and whether doc has a kind at all.
+func getKubeMeta(doc interface{}) (kubeMeta, bool) {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return kubeMeta{}, false
+	}
+
+	var km kubeMeta
+	km.Kind, _ = m["kind"].(string)
+	if md, ok := m["metadata"].(map[string]interface{}); ok {
+		km.Namespace, _ = md["namespace"].(string)
+		km.Name, _ = md["name"].(string)
+	}
+	return km, km.Kind != ""
+}
+
+// kubeRank orders objects so that those which others depend on are applied first.
+func kubeRank(km kubeMeta, ok bool) int {
+	switch {
+	case !ok:
+		// Not a Kubernetes object; keep at the end.
+		return 3
+	case km.Kind == "Namespace":
+		return 0
+	case km.Kind == "CustomResourceDefinition":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// sortKube sorts docs in place into a stable order:
+// Namespaces, then CustomResourceDefinitions, then everything else ordered by kind, namespace, and name.
+// Documents that are not Kubernetes objects stay at the end in their original order.
+func sortKube(docs []interface{}) {
+	metas := make([]kubeMeta, len(docs))
+	ranks := make([]int, len(docs))
+	for i, doc := range docs {
+		km, ok := getKubeMeta(doc)
+		metas[i] = km
+		ranks[i] = kubeRank(km, ok)
+	}
+
+	sort.Stable(kubeSorter{docs: docs, metas: metas, ranks: ranks})
+}
+
+type kubeSorter struct {
+	docs  []interface{}
+	metas []kubeMeta
+	ranks []int
+}
+
+func (s kubeSorter) Len() int { return len(s.docs) }
+
+func (s kubeSorter) Swap(i, j int) {
+	s.docs[i], s.docs[j] = s.docs[j], s.docs[i]
+	s.metas[i], s.metas[j] = s.metas[j], s.metas[i]
+	s.ranks[i], s.ranks[j] = s.ranks[j], s.ranks[i]
+}
+
+func (s kubeSorter) Less(i, j int) bool {
+	if s.ranks[i] != s.ranks[j] {
+		return s.ranks[i] < s.ranks[j]
+	}
+	if s.ranks[i] == 3 {
+		// Non-Kubernetes documents keep their order.
+		return false
+	}
+
+	a, b := s.metas[i], s.metas[j]
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// kubeSplitPath returns the path within outDir where doc is written in split mode.
+func kubeSplitPath(outDir string, doc interface{}) (string, error) {
+	km, ok := getKubeMeta(doc)
+	if !ok {
+		return "", fmt.Errorf("document is not a Kubernetes object with a kind")
+	}
+	if km.Name == "" {
+		return "", fmt.Errorf("%s has no metadata.name", km.Kind)
+	}
+	if strings.ContainsAny(km.Name+km.Namespace+km.Kind, `/\`) {
+		return "", fmt.Errorf("%s %q has a path separator in its kind, namespace, or name", km.Kind, km.Name)
+	}
+	for _, s := range []string{km.Namespace, km.Name} {
+		if s == "." || s == ".." {
+			return "", fmt.Errorf("%s %q has %q as its namespace or name", km.Kind, km.Name, s)
+		}
+	}
+
+	path := filepath.Join(outDir, km.Namespace, strings.ToLower(km.Kind)+"-"+km.Name+".yaml")
+
+	// Whatever the checks above miss, never write outside outDir.
+	if rel, err := filepath.Rel(outDir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s %q would be written outside %s", km.Kind, km.Name, outDir)
+	}
+	return path, nil
+}
+
+// writeKubeSplit writes each of docs to its own file under req.OutPath using encode,
+// and returns the total number of bytes written.
+func (p *Processor) writeKubeSplit(req writeRequest, docs []interface{}, encode encodeFunc) (int64, error) {
+	paths := make([]string, len(docs))
+	seen := make(map[string]int, len(docs))
+	for i, doc := range docs {
+		path, err := kubeSplitPath(req.OutPath, doc)
+		if err != nil {
+			return 0, fmt.Errorf("error determining path of document %d: %v", i, err)
+		}
+		if prev, ok := seen[path]; ok {
+			return 0, fmt.Errorf("documents %d and %d would both be written to %s", prev, i, path)
+		}
+		seen[path] = i
+		paths[i] = path
+	}
+
+	var total int64
+	for i, doc := range docs {
+		if err := p.fs.MkdirAll(filepath.Dir(paths[i]), 0777); err != nil {
+			return total, err
+		}
+
+		docReq := req
+		docReq.OutPath = paths[i]
+		n, err := p.writeEncoded(docReq, []interface{}{doc}, encode)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		p.recordOutput(paths[i])
+	}
+
+	return total, nil
+}
@@ -0,0 +1,141 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+const kubeJsonnet = `
+local obj(kind, name, ns=null) = {
+  apiVersion: 'v1',
+  kind: kind,
+  metadata: {name: name} + (if ns == null then {} else {namespace: ns}),
+};
+[
+  obj('Service', 'web', 'prod'),
+  {apiVersion: 'v1', kind: 'List', items: [
+    obj('ConfigMap', 'b', 'prod'),
+    obj('ConfigMap', 'a', 'prod'),
+  ]},
+  obj('CustomResourceDefinition', 'widgets.example.com'),
+  obj('Namespace', 'prod'),
+]
+`
+
+func TestProcessor_KubeSort(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.KubeSort = true
+
+	JY{J: kubeJsonnet}.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Fatalf("expected empty log, got %q", got)
+	}
+
+	got, err := afero.ReadFile(fs, "out.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(got), "\n") {
+		if strings.HasPrefix(line, "    name: ") {
+			names = append(names, strings.TrimPrefix(line, "    name: "))
+		}
+	}
+	want := "prod widgets.example.com a b web"
+	if strings.Join(names, " ") != want {
+		t.Fatalf("expected objects in order %q, got %q:\n%s", want, names, got)
+	}
+}
+
+func TestProcessor_KubeSplit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.KubeSplit = true
+
+	JY{J: kubeJsonnet}.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out")
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Fatalf("expected empty log, got %q", got)
+	}
+
+	JY{Y: `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+    name: a
+    namespace: prod
+...
+`}.ExpectY(t, fs, "out/prod/configmap-a.yaml")
+
+	for _, path := range []string{
+		"out/prod/configmap-b.yaml",
+		"out/prod/service-web.yaml",
+		"out/namespace-prod.yaml",
+		"out/customresourcedefinition-widgets.example.com.yaml",
+	} {
+		if _, err := fs.Stat(path); err != nil {
+			t.Errorf("expected %s to be written: %v", path, err)
+		}
+	}
+}
+
+func TestProcessor_KubeSplit_Errors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		j    string
+		want string
+	}{
+		"not an object": {
+			j:    `[[1, 2]]`,
+			want: "error determining path of document 0: document is not a Kubernetes object with a kind",
+		},
+		"missing name": {
+			j:    `[{kind: 'ConfigMap', metadata: {}}]`,
+			want: "error determining path of document 0: ConfigMap has no metadata.name",
+		},
+		"parent namespace": {
+			j:    `[{kind: 'ConfigMap', metadata: {name: 'a', namespace: '..'}}]`,
+			want: `error determining path of document 0: ConfigMap "a" has ".." as its namespace or name`,
+		},
+		"current namespace": {
+			j:    `[{kind: 'ConfigMap', metadata: {name: 'a', namespace: '.'}}]`,
+			want: `error determining path of document 0: ConfigMap "a" has "." as its namespace or name`,
+		},
+		"duplicate": {
+			j:    `[{kind: 'ConfigMap', metadata: {name: 'a'}}, {kind: 'ConfigMap', metadata: {name: 'a'}}]`,
+			want: "documents 0 and 1 would both be written to out/configmap-a.yaml",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			log := new(bytes.Buffer)
+			p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+			p.KubeSplit = true
+
+			JY{J: tt.j}.WriteJ(t, fs, "in.jsonnet")
+
+			p.Process("in.jsonnet", "out")
+			p.Close()
+
+			if got := log.String(); !strings.Contains(got, tt.want) {
+				t.Fatalf("expected log %q to contain %q but it didn't", got, tt.want)
+			}
+		})
+	}
+}
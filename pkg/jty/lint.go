@@ -0,0 +1,122 @@
+package jty
+
+import (
+	"fmt"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/linter"
+)
+
+// Lint severities, for Processor.LintFailOn.
+// go-jsonnet's linter doesn't classify its findings,
+// so unused variables are warnings and everything else is an error.
+// Unused variables are recognized by the text of the linter's message;
+// if a go-jsonnet upgrade rewords it, TestCommand_Lint fails.
+const (
+	LintSeverityWarning = "warning"
+	LintSeverityError   = "error"
+
+	// Never fail because of lint findings; only log them.
+	LintSeverityNone = "none"
+)
+
+// checkLintSeverity returns an error if s is not one of the LintSeverity constants.
+func checkLintSeverity(s string) error {
+	switch s {
+	case LintSeverityWarning, LintSeverityError, LintSeverityNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown lint severity %q: must be %q, %q, or %q", s, LintSeverityWarning, LintSeverityError, LintSeverityNone)
+	}
+}
+
+type lintFinding struct {
+	severity string
+
+	// Formatted like an evaluation error, with the location and the offending code.
+	message string
+}
+
+// findingCollector receives the linter's output, which writes each finding in a single call.
+type findingCollector struct {
+	findings []lintFinding
+}
+
+func (c *findingCollector) Write(b []byte) (int, error) {
+	msg := strings.TrimRight(string(b), "\n")
+	severity := LintSeverityError
+	if i := strings.IndexByte(msg, ' '); i >= 0 && strings.HasPrefix(msg[i+1:], "Unused variable:") {
+		severity = LintSeverityWarning
+	}
+	c.findings = append(c.findings, lintFinding{severity: severity, message: msg})
+	return len(b), nil
+}
+
+// lintJsonnet lints code with vm.
+// The linter only uses imported files as type information, without reporting findings in them.
+// Imports are resolved through vm, so they are only parsed once between linting and evaluation.
+func lintJsonnet(vm *jsonnet.VM, filename, code string) []lintFinding {
+	var c findingCollector
+	linter.LintSnippet(vm, &c, filename, code)
+	return c.findings
+}
+
+// failsAt reports whether a finding of severity fails a pair, given the threshold failOn.
+func failsAt(severity, failOn string) bool {
+	switch failOn {
+	case LintSeverityNone:
+		return false
+	case LintSeverityWarning:
+		return true
+	default:
+		return severity == LintSeverityError
+	}
+}
+
+// lint reports the lint findings for req, which has already evaluated successfully with vm,
+// and for each file it imports that no earlier job imported.
+func (p *Processor) lint(vm *jsonnet.VM, req evalRequest) {
+	p.reportLint(req, req.InPath, lintJsonnet(vm, req.InPath, req.JsonnetContent))
+
+	node, err := jsonnet.SnippetToAST(req.InPath, req.JsonnetContent)
+	if err != nil {
+		// Can't happen: the input evaluated successfully.
+		return
+	}
+	p.lintImports(vm, req, req.InPath, node)
+}
+
+// lintImports lints each Jsonnet file imported by node, which is the file at from, and its imports in turn,
+// skipping files that have already been linted.
+// Only accessed from the evaluate goroutine.
+func (p *Processor) lintImports(vm *jsonnet.VM, req evalRequest, from string, node ast.Node) {
+	for _, imp := range importedPaths(node) {
+		if !imp.parse {
+			continue
+		}
+		code, foundAt, err := vm.ImportData(from, imp.path)
+		if err != nil || p.linted[foundAt] {
+			// The linter has already reported an import that can't be resolved.
+			continue
+		}
+		p.linted[foundAt] = true
+
+		p.reportLint(req, foundAt, lintJsonnet(vm, foundAt, code))
+		if imported, err := jsonnet.SnippetToAST(foundAt, code); err == nil {
+			p.lintImports(vm, req, foundAt, imported)
+		}
+	}
+}
+
+// reportLint logs findings in the file at path, failing req for those at or above p.LintFailOn.
+func (p *Processor) reportLint(req evalRequest, path string, findings []lintFinding) {
+	for _, f := range findings {
+		if failsAt(f.severity, p.LintFailOn) {
+			p.fail(req.Result, fmt.Errorf("lint %s in %s: %s", f.severity, path, f.message))
+		} else {
+			p.logf("lint %s in %s: %s", f.severity, path, f.message)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package jty_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+const lintJsonnet = `local unused = 1;
+local f(a) = a;
+[{ bad:: f(1, 2), v: 1 }]
+`
+
+func TestCommand_Lint(t *testing.T) {
+	for _, tc := range []struct {
+		failOn       string
+		wantErrors   []string
+		wantWarnings []string
+	}{
+		{
+			failOn:       "",
+			wantErrors:   []string{"lint error in in.jsonnet: in.jsonnet:3:15-16 Too many arguments"},
+			wantWarnings: []string{"lint warning in in.jsonnet: in.jsonnet:1:7-17 Unused variable: unused"},
+		},
+		{
+			failOn: jty.LintSeverityWarning,
+			wantErrors: []string{
+				"lint error in in.jsonnet: in.jsonnet:3:15-16 Too many arguments",
+				"lint warning in in.jsonnet: in.jsonnet:1:7-17 Unused variable: unused",
+			},
+		},
+		{
+			failOn: jty.LintSeverityNone,
+			wantWarnings: []string{
+				"lint error in in.jsonnet: in.jsonnet:3:15-16 Too many arguments",
+				"lint warning in in.jsonnet: in.jsonnet:1:7-17 Unused variable: unused",
+			},
+		},
+	} {
+		tc := tc
+		t.Run("fail on "+tc.failOn, func(t *testing.T) {
+			cmd := NewTestCommand("")
+			JY{J: lintJsonnet}.WriteJ(t, cmd.FS, "in.jsonnet")
+
+			// The input is given twice, but only linted once.
+			err := cmd.Cmd.Run(&jty.Flags{
+				Args:       []string{"in.jsonnet", "out.yml", "in.jsonnet", "out2.yml"},
+				Lint:       true,
+				LintFailOn: tc.failOn,
+			})
+			if len(tc.wantErrors) > 0 && err != jty.ErrEncounteredErrors {
+				t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+			}
+			if len(tc.wantErrors) == 0 && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			got := cmd.Stderr.String()
+			for _, want := range append(tc.wantErrors, tc.wantWarnings...) {
+				if n := strings.Count(got, want); n != 1 {
+					t.Errorf("expected stderr to contain %q once, found it %d times in:\n%s", want, n, got)
+				}
+			}
+
+			// Output is written regardless.
+			JY{Y: "---\nv: 1\n...\n"}.ExpectY(t, cmd.FS, "out.yml")
+		})
+	}
+}
+
+func TestCommand_Lint_Imports(t *testing.T) {
+	libdir, err := ioutil.TempDir("", "jty-lint-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(libdir)
+
+	lib := filepath.Join(libdir, "lib.libsonnet")
+	nested := filepath.Join(libdir, "nested.libsonnet")
+	for path, code := range map[string]string{
+		lib:    "local unused = 1;\nlocal n = import 'nested.libsonnet';\n{ x: n.y }\n",
+		nested: "local alsoUnused = 2;\n{ y: 1 }\n",
+	} {
+		if err := ioutil.WriteFile(path, []byte(code), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := NewTestCommand("")
+	// Both inputs are clean; the findings are only in the imports.
+	JY{J: "[import 'lib.libsonnet']"}.WriteJ(t, cmd.FS, "in1.jsonnet")
+	JY{J: "[(import 'lib.libsonnet').x]"}.WriteJ(t, cmd.FS, "in2.jsonnet")
+
+	err = cmd.Cmd.Run(&jty.Flags{
+		Args:       []string{"in1.jsonnet", "out1.yml", "in2.jsonnet", "out2.yml"},
+		JPaths:     []string{libdir},
+		Lint:       true,
+		LintFailOn: jty.LintSeverityWarning,
+	})
+	if err != jty.ErrEncounteredErrors {
+		t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+	}
+
+	// Each import is linted once, even though both inputs import it.
+	got := cmd.Stderr.String()
+	for _, want := range []string{
+		"lint warning in " + lib + ": " + lib + ":1:7-17 Unused variable: unused",
+		"lint warning in " + nested + ": " + nested + ":1:7-21 Unused variable: alsoUnused",
+	} {
+		if n := strings.Count(got, want); n != 1 {
+			t.Errorf("expected stderr to contain %q once, found it %d times in:\n%s", want, n, got)
+		}
+	}
+}
+
+func TestProcessor_Lint_Clean(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.Lint = true
+	p.LintFailOn = jty.LintSeverityWarning
+
+	JYOneTwo.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Fatalf("expected empty log, got %q", got)
+	}
+}
+
+func TestCommand_InvalidLintFailOn(t *testing.T) {
+	tc := NewTestCommand("")
+
+	err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, Lint: true, LintFailOn: "info"})
+	if err == nil || !strings.Contains(err.Error(), `unknown lint severity "info"`) {
+		t.Fatalf("expected invalid severity error, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package jty
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrMatrixWithoutOut = errors.New("--matrix and --matrix-tla require --out")
+	ErrOutWithStdin     = errors.New("--out cannot be used with --stdin")
+	ErrOutWithExec      = errors.New("--out cannot be used with --exec")
+)
+
+// matrixVar is one variable of a matrix: every value it is bound to in turn,
+// either as an external variable or as a top-level argument.
+type matrixVar struct {
+	name   string
+	values []string
+	tla    bool
+}
+
+// Placeholders in an --out template that are filled in from the input path,
+// and so cannot be used as matrix variable names.
+const (
+	outStem = "stem"
+	outDir  = "dir"
+)
+
+// parseMatrix parses the NAME=V1,V2 values of the given flag.
+func parseMatrix(flagName string, specs []string, tla bool) ([]matrixVar, error) {
+	vars := make([]matrixVar, len(specs))
+	for i, spec := range specs {
+		name, list, err := splitAssignment(flagName, spec)
+		if err != nil {
+			return nil, err
+		}
+		if name == outStem || name == outDir {
+			return nil, fmt.Errorf("invalid %s value %q: %q is reserved for the --out template", flagName, spec, name)
+		}
+
+		values := strings.Split(list, ",")
+		for _, v := range values {
+			if v == "" {
+				return nil, fmt.Errorf("invalid %s value %q: values must not be empty", flagName, spec)
+			}
+		}
+		vars[i] = matrixVar{name: name, values: values, tla: tla}
+	}
+	return vars, nil
+}
+
+var outPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// checkOutTemplate reports an error if tmpl uses an unknown placeholder,
+// or doesn't use every matrix variable, which would make several jobs write the same output.
+func checkOutTemplate(tmpl string, vars []matrixVar) error {
+	used := make(map[string]bool)
+	for _, m := range outPlaceholder.FindAllStringSubmatch(tmpl, -1) {
+		used[m[1]] = true
+	}
+
+	known := map[string]bool{outStem: true, outDir: true}
+	for _, v := range vars {
+		if known[v.name] {
+			return fmt.Errorf("matrix variable %q is given more than once", v.name)
+		}
+		known[v.name] = true
+
+		if !used[v.name] {
+			return fmt.Errorf("--out template %q must use every matrix variable, but doesn't use {%s}", tmpl, v.name)
+		}
+	}
+	for name := range used {
+		if !known[name] {
+			return fmt.Errorf("--out template %q uses unknown placeholder {%s}", tmpl, name)
+		}
+	}
+	return nil
+}
+
+// expandOutTemplate returns the output path for inPath with the matrix values in values.
+// Placeholders have already been validated by checkOutTemplate.
+func expandOutTemplate(tmpl, inPath string, values map[string]string) string {
+	base := filepath.Base(inPath)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	out := outPlaceholder.ReplaceAllStringFunc(tmpl, func(p string) string {
+		switch name := p[1 : len(p)-1]; name {
+		case outStem:
+			return stem
+		case outDir:
+			return filepath.Dir(inPath)
+		default:
+			return values[name]
+		}
+	})
+	return filepath.Clean(out)
+}
+
+// matrixJobs returns a job for every combination of values of vars for each of inPaths,
+// with the output path given by the --out template tmpl.
+// Inputs vary slowest, then the variables in the order given.
+func matrixJobs(inPaths []string, vars []matrixVar, tmpl string) []Job {
+	var jobs []Job
+	for _, in := range inPaths {
+		values := make(map[string]string, len(vars))
+
+		var expand func(i int)
+		expand = func(i int) {
+			if i < len(vars) {
+				for _, v := range vars[i].values {
+					values[vars[i].name] = v
+					expand(i + 1)
+				}
+				return
+			}
+
+			j := Job{InPath: in, OutPath: expandOutTemplate(tmpl, in, values)}
+			for _, v := range vars {
+				if v.tla {
+					if j.TLAVars == nil {
+						j.TLAVars = make(map[string]string)
+					}
+					j.TLAVars[v.name] = values[v.name]
+				} else {
+					if j.ExtVars == nil {
+						j.ExtVars = make(map[string]string)
+					}
+					j.ExtVars[v.name] = values[v.name]
+				}
+			}
+			jobs = append(jobs, j)
+		}
+		expand(0)
+	}
+	return jobs
+}
+
+// outJobs returns the jobs for the inputs in f.Args, with outputs named by f.Out.
+func outJobs(f *Flags) ([]Job, error) {
+	vars, err := parseMatrix("--matrix", f.Matrix, false)
+	if err != nil {
+		return nil, err
+	}
+	tlaVars, err := parseMatrix("--matrix-tla", f.MatrixTLA, true)
+	if err != nil {
+		return nil, err
+	}
+	vars = append(vars, tlaVars...)
+
+	if err := checkOutTemplate(f.Out, vars); err != nil {
+		return nil, err
+	}
+	return matrixJobs(f.Args, vars, f.Out), nil
+}
@@ -0,0 +1,172 @@
+package jty_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+)
+
+func TestCommand_Matrix(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `function(replicas) [{env: std.extVar('env'), region: std.extVar('region'), replicas: std.parseInt(replicas)}]`}.WriteJ(t, tc.FS, "apps/app.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args:      []string{"apps/app.jsonnet"},
+		Out:       "{dir}/out/{stem}.{env}-{region}.{replicas}.yml",
+		Matrix:    []string{"env=dev,prod", "region=us,eu"},
+		MatrixTLA: []string{"replicas=1,3"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, env := range []string{"dev", "prod"} {
+		for _, region := range []string{"us", "eu"} {
+			for _, replicas := range []string{"1", "3"} {
+				JY{Y: "---\nenv: " + env + "\nregion: " + region + "\nreplicas: " + replicas + "\n...\n"}.
+					ExpectY(t, tc.FS, "apps/out/app."+env+"-"+region+"."+replicas+".yml")
+			}
+		}
+	}
+
+	if tc.Stderr.String() != "" {
+		t.Fatalf("expected no standard error, got %q", tc.Stderr.String())
+	}
+}
+
+func TestCommand_Matrix_SharedImports(t *testing.T) {
+	libdir, err := ioutil.TempDir("", "jty-matrix-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(libdir)
+
+	// The library counts how many times it is evaluated.
+	if err := ioutil.WriteFile(filepath.Join(libdir, "lib.libsonnet"), []byte("{ n: std.native('count')() }"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		matrix, matrixTLA []string
+		want              int
+	}{
+		// Top-level arguments don't affect imports, so the library is evaluated once.
+		"tla": {matrixTLA: []string{"a=1,2,3"}, want: 1},
+
+		// Imports may read external variables, so the library is evaluated again for each value,
+		// but still parsed only once.
+		"ext": {matrix: []string{"a=1,2,3"}, want: 3},
+	} {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			tc := NewTestCommand("")
+			count := 0
+			tc.Cmd.NativeFunctions = []*jsonnet.NativeFunction{{
+				Name:   "count",
+				Params: ast.Identifiers{},
+				Func: func([]interface{}) (interface{}, error) {
+					count++
+					return float64(count), nil
+				},
+			}}
+			code := `[(import 'lib.libsonnet').n]`
+			if len(tt.matrixTLA) > 0 {
+				code = `function(a) ` + code
+			}
+			JY{J: code}.WriteJ(t, tc.FS, "app.jsonnet")
+
+			if err := tc.Cmd.Run(&jty.Flags{
+				Args:      []string{"app.jsonnet"},
+				Out:       "{stem}.{a}.yml",
+				JPaths:    []string{libdir},
+				Matrix:    tt.matrix,
+				MatrixTLA: tt.matrixTLA,
+			}); err != nil {
+				t.Fatalf("unexpected error %v: %s", err, tc.Stderr.String())
+			}
+
+			if count != tt.want {
+				t.Fatalf("expected the library to be evaluated %d times, got %d", tt.want, count)
+			}
+		})
+	}
+}
+
+func TestCommand_Out(t *testing.T) {
+	tc := NewTestCommand("")
+	JYOneTwo.WriteJ(t, tc.FS, "a.jsonnet")
+	JYOneTwo.WriteJ(t, tc.FS, "sub/b.jsonnet")
+
+	// Without a matrix, --out just names each input's single output.
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args: []string{"a.jsonnet", "sub/b.jsonnet", "a.jsonnet"},
+		Out:  "{dir}/{stem}.yml",
+	}); err == nil {
+		t.Fatal("expected an error for an input given twice")
+	}
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args: []string{"a.jsonnet", "sub/b.jsonnet"},
+		Out:  "{dir}/{stem}.yml",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	JYOneTwo.ExpectY(t, tc.FS, "a.yml")
+	JYOneTwo.ExpectY(t, tc.FS, "sub/b.yml")
+}
+
+func TestCommand_Matrix_Errors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		flags jty.Flags
+		want  string
+	}{
+		"without out": {
+			flags: jty.Flags{Args: []string{"a.jsonnet", "a.yml"}, Matrix: []string{"env=dev"}},
+			want:  jty.ErrMatrixWithoutOut.Error(),
+		},
+		"with stdin": {
+			flags: jty.Flags{FromStdin: true, Out: "{stem}.yml"},
+			want:  jty.ErrOutWithStdin.Error(),
+		},
+		"with exec": {
+			flags: jty.Flags{Exec: true, Args: []string{"{}"}, Out: "{stem}.yml"},
+			want:  jty.ErrOutWithExec.Error(),
+		},
+		"malformed": {
+			flags: jty.Flags{Args: []string{"a.jsonnet"}, Out: "{stem}.yml", Matrix: []string{"env"}},
+			want:  `invalid --matrix value "env": must be formatted as KEY=VALUE`,
+		},
+		"empty value": {
+			flags: jty.Flags{Args: []string{"a.jsonnet"}, Out: "{stem}.{env}.yml", Matrix: []string{"env=dev,,prod"}},
+			want:  `invalid --matrix value "env=dev,,prod": values must not be empty`,
+		},
+		"reserved name": {
+			flags: jty.Flags{Args: []string{"a.jsonnet"}, Out: "{stem}.yml", MatrixTLA: []string{"stem=a,b"}},
+			want:  `invalid --matrix-tla value "stem=a,b": "stem" is reserved for the --out template`,
+		},
+		"duplicate name": {
+			flags: jty.Flags{Args: []string{"a.jsonnet"}, Out: "{stem}.{env}.yml", Matrix: []string{"env=dev"}, MatrixTLA: []string{"env=prod"}},
+			want:  `matrix variable "env" is given more than once`,
+		},
+		"unused variable": {
+			flags: jty.Flags{Args: []string{"a.jsonnet"}, Out: "{stem}.yml", Matrix: []string{"env=dev,prod"}},
+			want:  `--out template "{stem}.yml" must use every matrix variable, but doesn't use {env}`,
+		},
+		"unknown placeholder": {
+			flags: jty.Flags{Args: []string{"a.jsonnet"}, Out: "{stem}.{env}.yml"},
+			want:  `--out template "{stem}.{env}.yml" uses unknown placeholder {env}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc := NewTestCommand("")
+			err := tc.Cmd.Run(&tt.flags)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package jty
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/afero"
+)
+
+// parseMode parses an octal permission string like "0644" or "755".
+func parseMode(s string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m == 0 || m > 0777 {
+		return 0, fmt.Errorf("%q is not an octal permission like 0644", s)
+	}
+	return os.FileMode(m), nil
+}
+
+// outputMode returns the permissions to give the file at req.OutPath,
+// or 0 to leave them to p.fs.
+func (p *Processor) outputMode(req writeRequest) os.FileMode {
+	if req.Mode != 0 {
+		return req.Mode
+	}
+	if p.KeepMode {
+		if fi, err := p.fs.Stat(req.OutPath); err == nil && fi.Mode().IsRegular() {
+			return fi.Mode().Perm()
+		}
+	}
+	return p.Mode
+}
+
+// createOutput creates or truncates the file at req.OutPath for writing.
+// If outputMode gives a mode, the file is set to exactly that mode, regardless of the umask,
+// before anything is written to it.
+func (p *Processor) createOutput(req writeRequest) (afero.File, error) {
+	mode := p.outputMode(req)
+	if mode == 0 {
+		return p.fs.Create(req.OutPath)
+	}
+
+	f, err := p.fs.OpenFile(req.OutPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.fs.Chmod(req.OutPath, mode); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,81 @@
+package jty_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func expectMode(t *testing.T, fs afero.Fs, path string, want os.FileMode) {
+	t.Helper()
+
+	fi, err := fs.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != want {
+		t.Fatalf("expected %s to have mode %v, got %v", path, want, got)
+	}
+}
+
+func TestCommand_Mode(t *testing.T) {
+	tc := NewTestCommand("")
+	JYOneTwo.WriteJ(t, tc.FS, "in.jsonnet")
+	if err := afero.WriteFile(tc.FS, "existing.yml", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		Args: []string{"in.jsonnet", "new.yml", "in.jsonnet", "existing.yml"},
+		Mode: "0755",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	JYOneTwo.ExpectY(t, tc.FS, "new.yml")
+	expectMode(t, tc.FS, "new.yml", 0755)
+	expectMode(t, tc.FS, "existing.yml", 0755)
+}
+
+func TestCommand_KeepMode(t *testing.T) {
+	stdin := `{"in": "in.jsonnet", "out": "new.yml"}
+{"in": "in.jsonnet", "out": "existing.yml"}
+{"in": "in.jsonnet", "out": "override.yml", "mode": "0640"}
+`
+	tc := NewTestCommand(stdin)
+	JYOneTwo.WriteJ(t, tc.FS, "in.jsonnet")
+	for _, path := range []string{"existing.yml", "override.yml"} {
+		if err := afero.WriteFile(tc.FS, path, nil, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		StdinFormat: jty.StdinFormatJSONL,
+		FromStdin:   true,
+		Mode:        "600",
+		KeepMode:    true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new file takes --mode, an existing file keeps its mode,
+	// and a per-pair mode applies either way.
+	expectMode(t, tc.FS, "new.yml", 0600)
+	expectMode(t, tc.FS, "existing.yml", 0700)
+	expectMode(t, tc.FS, "override.yml", 0640)
+	JYOneTwo.ExpectY(t, tc.FS, "existing.yml")
+}
+
+func TestCommand_Mode_Invalid(t *testing.T) {
+	for _, mode := range []string{"rw", "0", "1777", "-644"} {
+		tc := NewTestCommand("")
+		err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, Mode: mode})
+		want := `invalid --mode: "` + mode + `" is not an octal permission like 0644`
+		if err == nil || err.Error() != want {
+			t.Fatalf("expected error %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package jty
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/spf13/afero"
+)
+
+// NativeLibrary returns jty's built-in native functions,
+// which Jsonnet calls through std.native, e.g. std.native('semverCompare')('1.2.0', '1.10.0').
+// Files named in arguments are read from fs, relative to the working directory.
+// SandboxedNativeLibrary restricts them to the same roots as --import-root.
+//
+//	sha256File(path): the hex SHA-256 digest of the file at path.
+//	base64Gzip(str): str compressed with gzip, then base64-encoded.
+//	semverCompare(a, b): -1, 0, or 1 as semantic version a is lower than, equal to, or higher than b.
+//	cidrSubnet(prefix, newbits, netnum): subnet number netnum of prefix, extended by newbits bits.
+//	cidrHost(prefix, hostnum): the address of host number hostnum in prefix; negative numbers count back from the end.
+func NativeLibrary(fs afero.Fs) []*jsonnet.NativeFunction {
+	return nativeLibrary(fs, nil)
+}
+
+// SandboxedNativeLibrary is like NativeLibrary,
+// but natives refuse to read files that resolve outside roots, after following symlinks,
+// as imports do with --import-root.
+func SandboxedNativeLibrary(fs afero.Fs, roots []string) []*jsonnet.NativeFunction {
+	canonical := make([]string, len(roots))
+	for i, r := range roots {
+		canonical[i] = canonicalPath(fs, r)
+	}
+	return nativeLibrary(fs, canonical)
+}
+
+// nativeLibrary returns the built-in natives, reading files from fs.
+// If roots is not nil, files must resolve within one of those canonical directories.
+func nativeLibrary(fs afero.Fs, roots []string) []*jsonnet.NativeFunction {
+	return []*jsonnet.NativeFunction{
+		{
+			Name:   "sha256File",
+			Params: ast.Identifiers{"path"},
+			Func: func(args []interface{}) (interface{}, error) {
+				path, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("path must be a string, got %s", jsonTypeName(args[0]))
+				}
+				if roots != nil {
+					p := canonicalPath(fs, path)
+					if !withinRoots(p, roots) {
+						return nil, fmt.Errorf("refusing to read %s: it resolves to %s, outside the allowed import roots", path, p)
+					}
+					// Read what was checked, not whatever path resolves to by the time it's opened.
+					path = p
+				}
+				content, err := afero.ReadFile(fs, path)
+				if err != nil {
+					return nil, err
+				}
+				sum := sha256.Sum256(content)
+				return hex.EncodeToString(sum[:]), nil
+			},
+		},
+		{
+			Name:   "base64Gzip",
+			Params: ast.Identifiers{"str"},
+			Func: func(args []interface{}) (interface{}, error) {
+				s, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("str must be a string, got %s", jsonTypeName(args[0]))
+				}
+				return base64Gzip(s)
+			},
+		},
+		{
+			Name:   "semverCompare",
+			Params: ast.Identifiers{"a", "b"},
+			Func: func(args []interface{}) (interface{}, error) {
+				a, aOK := args[0].(string)
+				b, bOK := args[1].(string)
+				if !aOK || !bOK {
+					return nil, fmt.Errorf("versions must be strings, got %s and %s", jsonTypeName(args[0]), jsonTypeName(args[1]))
+				}
+				c, err := semverCompare(a, b)
+				if err != nil {
+					return nil, err
+				}
+				return float64(c), nil
+			},
+		},
+		{
+			Name:   "cidrSubnet",
+			Params: ast.Identifiers{"prefix", "newbits", "netnum"},
+			Func: func(args []interface{}) (interface{}, error) {
+				prefix, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("prefix must be a string, got %s", jsonTypeName(args[0]))
+				}
+				newbits, err := integerArg("newbits", args[1])
+				if err != nil {
+					return nil, err
+				}
+				netnum, err := integerArg("netnum", args[2])
+				if err != nil {
+					return nil, err
+				}
+				return cidrSubnet(prefix, newbits, netnum)
+			},
+		},
+		{
+			Name:   "cidrHost",
+			Params: ast.Identifiers{"prefix", "hostnum"},
+			Func: func(args []interface{}) (interface{}, error) {
+				prefix, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("prefix must be a string, got %s", jsonTypeName(args[0]))
+				}
+				hostnum, err := integerArg("hostnum", args[1])
+				if err != nil {
+					return nil, err
+				}
+				return cidrHost(prefix, hostnum)
+			},
+		},
+	}
+}
+
+// integerArg returns v as an int64 if it is a number with no fractional part.
+func integerArg(name string, v interface{}) (int64, error) {
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s must be a number, got %s", name, jsonTypeName(v))
+	}
+	if f != math.Trunc(f) || math.Abs(f) >= 1<<53 {
+		return 0, fmt.Errorf("%s must be an integer, got %v", name, f)
+	}
+	return int64(f), nil
+}
+
+func base64Gzip(s string) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+type semver struct {
+	core [3]uint64
+	pre  []string
+}
+
+// parseSemver parses a semantic version such as 1.2.3-rc.1+build.5,
+// with an optional leading "v". Build metadata is ignored.
+func parseSemver(s string) (semver, error) {
+	var v semver
+
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		v.pre = strings.Split(rest[i+1:], ".")
+		rest = rest[:i]
+		for _, id := range v.pre {
+			if id == "" {
+				return v, fmt.Errorf("invalid semantic version %q: empty pre-release identifier", s)
+			}
+		}
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid semantic version %q: must have the form MAJOR.MINOR.PATCH", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid semantic version %q: %q is not a number", s, p)
+		}
+		v.core[i] = n
+	}
+
+	return v, nil
+}
+
+// semverCompare compares semantic versions a and b by their precedence.
+func semverCompare(a, b string) (int, error) {
+	va, err := parseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va.core {
+		if c := compareUint(va.core[i], vb.core[i]); c != 0 {
+			return c, nil
+		}
+	}
+
+	// A version without a pre-release has higher precedence than one with.
+	switch {
+	case len(va.pre) == 0 && len(vb.pre) == 0:
+		return 0, nil
+	case len(va.pre) == 0:
+		return 1, nil
+	case len(vb.pre) == 0:
+		return -1, nil
+	}
+
+	for i := 0; i < len(va.pre) && i < len(vb.pre); i++ {
+		if c := comparePrerelease(va.pre[i], vb.pre[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return compareUint(uint64(len(va.pre)), uint64(len(vb.pre))), nil
+}
+
+// comparePrerelease compares pre-release identifiers:
+// numerically if both are numeric, with numeric identifiers lower than alphanumeric ones,
+// and otherwise lexically.
+func comparePrerelease(a, b string) int {
+	na, aErr := strconv.ParseUint(a, 10, 64)
+	nb, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareUint(na, nb)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseCIDR returns the network address of prefix as a 4- or 16-byte IP, and its prefix length.
+func parseCIDR(prefix string) (net.IP, int, error) {
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, 0, err
+	}
+	ones, _ := ipNet.Mask.Size()
+	return ipNet.IP, ones, nil
+}
+
+// offsetIP returns ip with n added to the bits below the first prefixLen bits,
+// failing if n doesn't fit in those bits.
+func offsetIP(ip net.IP, prefixLen int, n *big.Int) (net.IP, error) {
+	hostBits := uint(len(ip)*8 - prefixLen)
+	limit := new(big.Int).Lsh(big.NewInt(1), hostBits)
+	if n.Sign() < 0 || n.Cmp(limit) >= 0 {
+		return nil, fmt.Errorf("%v does not fit in %d bits", n, hostBits)
+	}
+
+	sum := new(big.Int).Add(new(big.Int).SetBytes(ip), n)
+	out := make(net.IP, len(ip))
+	b := sum.Bytes()
+	copy(out[len(out)-len(b):], b)
+	return out, nil
+}
+
+// cidrSubnet returns subnet number netnum of prefix, with newbits more bits of prefix length.
+func cidrSubnet(prefix string, newbits, netnum int64) (string, error) {
+	ip, ones, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	newLen := int64(ones) + newbits
+	if newbits < 0 || newLen > int64(len(ip)*8) {
+		return "", fmt.Errorf("cannot extend prefix %s by %d bits", prefix, newbits)
+	}
+
+	shifted := new(big.Int).Lsh(big.NewInt(netnum), uint(int64(len(ip)*8)-newLen))
+	subnet, err := offsetIP(ip, ones, shifted)
+	if err != nil {
+		return "", fmt.Errorf("network number %d does not fit in %d new bits of %s", netnum, newbits, prefix)
+	}
+
+	return fmt.Sprintf("%s/%d", subnet, newLen), nil
+}
+
+// cidrHost returns the address of host number hostnum in prefix.
+// A negative hostnum counts back from the last address.
+func cidrHost(prefix string, hostnum int64) (string, error) {
+	ip, ones, err := parseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	n := big.NewInt(hostnum)
+	if hostnum < 0 {
+		size := new(big.Int).Lsh(big.NewInt(1), uint(len(ip)*8-ones))
+		n.Add(n, size)
+	}
+	host, err := offsetIP(ip, ones, n)
+	if err != nil {
+		return "", fmt.Errorf("host number %d is out of range for %s", hostnum, prefix)
+	}
+
+	return host.String(), nil
+}
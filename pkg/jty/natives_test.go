@@ -0,0 +1,136 @@
+package jty_test
+
+import (
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+// runNative evaluates code with the built-in native library enabled and returns the output YAML.
+func runNative(t *testing.T, tc *TestCommand, code string) (string, error) {
+	t.Helper()
+
+	JY{J: code}.WriteJ(t, tc.FS, "in.jsonnet")
+	err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, Natives: true})
+	if err != nil {
+		return tc.Stderr.String(), err
+	}
+
+	got, err := afero.ReadFile(tc.FS, "out.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got), nil
+}
+
+func TestNativeLibrary(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: "hello\n"}.WriteJ(t, tc.FS, "data.txt")
+
+	got, err := runNative(t, tc, `local n(name) = std.native(name);
+[{
+  sha: n('sha256File')('data.txt'),
+  gz: n('base64Gzip')('hello'),
+  semver: [
+    n('semverCompare')('1.2.0', '1.10.0'),
+    n('semverCompare')('v2.0.0', '2.0.0+build.1'),
+    n('semverCompare')('1.0.0', '1.0.0-rc.1'),
+    n('semverCompare')('1.0.0-alpha.10', '1.0.0-alpha.9'),
+    n('semverCompare')('1.0.0-alpha', '1.0.0-alpha.1'),
+    n('semverCompare')('1.0.0-1', '1.0.0-alpha'),
+  ],
+  subnets: [
+    n('cidrSubnet')('10.0.0.0/16', 8, 2),
+    n('cidrSubnet')('fd00::/48', 16, 255),
+  ],
+  hosts: [
+    n('cidrHost')('10.0.2.0/24', 5),
+    n('cidrHost')('10.0.2.0/24', -2),
+  ],
+}]`)
+	if err != nil {
+		t.Fatalf("unexpected error %v: %s", err, got)
+	}
+
+	want := `---
+gz: H4sIAAAAAAAA/wAFAPr/aGVsbG8DAIamEDYFAAAA
+hosts:
+  - 10.0.2.5
+  - 10.0.2.254
+semver:
+  - -1
+  - 0
+  - 1
+  - 1
+  - -1
+  - -1
+sha: 5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03
+subnets:
+  - 10.0.2.0/24
+  - fd00:0:0:ff::/64
+...
+`
+	if got != want {
+		t.Fatalf("expected output:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestNativeLibrary_Errors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		code, wantErr string
+	}{
+		"missing file":      {code: `std.native('sha256File')('nope.txt')`, wantErr: "nope.txt"},
+		"bad semver":        {code: `std.native('semverCompare')('1.2', '1.2.0')`, wantErr: `invalid semantic version "1.2"`},
+		"subnet overflow":   {code: `std.native('cidrSubnet')('10.0.0.0/16', 2, 4)`, wantErr: "network number 4 does not fit in 2 new bits of 10.0.0.0/16"},
+		"host out of range": {code: `std.native('cidrHost')('10.0.0.0/30', 4)`, wantErr: "host number 4 is out of range for 10.0.0.0/30"},
+		"non-integer":       {code: `std.native('cidrHost')('10.0.0.0/30', 1.5)`, wantErr: "hostnum must be an integer"},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			cmd := NewTestCommand("")
+			got, err := runNative(t, cmd, "["+tc.code+"]")
+			if err != jty.ErrEncounteredErrors {
+				t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+			}
+			if !strings.Contains(got, tc.wantErr) {
+				t.Fatalf("expected stderr to contain %q, got %q", tc.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestNativeLibrary_DisabledByDefault(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: `[std.native('base64Gzip')('x')]`}.WriteJ(t, tc.FS, "in.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}}); err != jty.ErrEncounteredErrors {
+		t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+	}
+	if !strings.Contains(tc.Stderr.String(), "expected function") {
+		t.Fatalf("expected error about unknown native function, got %q", tc.Stderr.String())
+	}
+}
+
+func TestCommand_NativeFunctions(t *testing.T) {
+	tc := NewTestCommand("")
+	tc.Cmd.NativeFunctions = []*jsonnet.NativeFunction{
+		{
+			Name:   "shout",
+			Params: ast.Identifiers{"s"},
+			Func: func(args []interface{}) (interface{}, error) {
+				return strings.ToUpper(args[0].(string)) + "!", nil
+			},
+		},
+	}
+	JY{J: `[std.native('shout')('hi')]`}.WriteJ(t, tc.FS, "in.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}}); err != nil {
+		t.Fatalf("unexpected error %v: %s", err, tc.Stderr.String())
+	}
+
+	JY{Y: "---\nHI!\n...\n"}.ExpectY(t, tc.FS, "out.yml")
+}
@@ -0,0 +1,133 @@
+package jty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// jsonNumber is a number exactly as it was written in JSON.
+// Documents hold numbers as jsonNumber rather than float64,
+// so that large integers keep every digit and no number gains an exponent it didn't have.
+type jsonNumber string
+
+// MarshalYAML writes n as a plain scalar, which YAML reads as a number because every JSON number is a YAML number.
+// A number too large for a float64 is an error, because YAML readers would read it as infinity.
+func (n jsonNumber) MarshalYAML() (interface{}, error) {
+	if err := n.checkYAML(); err != nil {
+		return nil, err
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: string(n)}, nil
+}
+
+// checkYAML returns an error if n is too large for a float64.
+func (n jsonNumber) checkYAML() error {
+	if _, err := strconv.ParseFloat(string(n), 64); err != nil {
+		return fmt.Errorf("number %s is too large to be read from YAML", n)
+	}
+	return nil
+}
+
+// checkYAMLNumbers returns an error for the first number in docs that is too large to write as YAML.
+func checkYAMLNumbers(docs []interface{}) error {
+	for i, doc := range docs {
+		if err := checkYAMLNumbersAt("", doc); err != nil {
+			return fmt.Errorf("document %d at %s", i, err)
+		}
+	}
+	return nil
+}
+
+// checkYAMLNumbersAt is checkYAMLNumbers for the value v at the JSON pointer path.
+func checkYAMLNumbersAt(path string, v interface{}) error {
+	switch v := v.(type) {
+	case jsonNumber:
+		if err := v.checkYAML(); err != nil {
+			if path == "" {
+				path = "/"
+			}
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	case []interface{}:
+		for i, e := range v {
+			if err := checkYAMLNumbersAt(path+"/"+strconv.Itoa(i), e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := checkYAMLNumbersAt(path+"/"+escapeJSONPointer(k), v[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isInteger reports whether n was written without a fraction or exponent.
+func (n jsonNumber) isInteger() bool {
+	return !strings.ContainsAny(string(n), ".eE")
+}
+
+// float64 returns n as the nearest float64.
+func (n jsonNumber) float64() float64 {
+	// Valid JSON numbers always parse; out-of-range values become infinities.
+	f, _ := strconv.ParseFloat(string(n), 64)
+	return f
+}
+
+// bigInt returns n as a big.Int, or nil if n is not an integer.
+func (n jsonNumber) bigInt() *big.Int {
+	if !n.isInteger() {
+		return nil
+	}
+	i, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		return nil
+	}
+	return i
+}
+
+// decodeJSON unmarshals the single JSON value in j, with numbers as jsonNumber.
+func decodeJSON(j string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(j))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return withJSONNumbers(v), nil
+}
+
+// withJSONNumbers replaces every json.Number in v with a jsonNumber, in place where possible.
+func withJSONNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		return jsonNumber(v)
+	case []interface{}:
+		for i, e := range v {
+			v[i] = withJSONNumbers(e)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = withJSONNumbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,180 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func TestProcessor_Numbers(t *testing.T) {
+	for name, tc := range map[string]struct {
+		in, out string
+		jy      JY
+	}{
+		"jsonnet": {
+			in:  "in.jsonnet",
+			out: "out.yml",
+			jy: JY{
+				// Jsonnet numbers are doubles, so some of these are rounded before jty sees them,
+				// but jty writes every one exactly as Jsonnet manifested it.
+				J: `[{
+  million: 1e6,
+  maxSafe: 9007199254740991,
+  aboveMaxSafe: 9007199254740993,
+  maxInt64: 9223372036854775807,
+  maxUint64: 18446744073709551615,
+  huge: 1e22,
+  negative: -9007199254740991,
+  negativeZero: -0,
+  tenth: 0.1,
+  small: 1.5e-7,
+}]`,
+				Y: `---
+aboveMaxSafe: 9007199254740992
+huge: 10000000000000000000000
+maxInt64: 9223372036854775808
+maxSafe: 9007199254740991
+maxUint64: 18446744073709551616
+million: 1000000
+negative: -9007199254740991
+negativeZero: -0
+small: 1.4999999999999999e-07
+tenth: 0.10000000000000001
+...
+`,
+			},
+		},
+		"json": {
+			in:  "in.json",
+			out: "out.yml",
+			jy: JY{
+				// Plain JSON inputs are not evaluated, so their numbers are never rounded.
+				J: `{"id": 12345678901234567890123, "ids": [9007199254740993, -9223372036854775809], "pi": 3.14159265358979323846, "exp": 1e+06}`,
+				Y: `---
+exp: 1e+06
+id: 12345678901234567890123
+ids:
+  - 9007199254740993
+  - -9223372036854775809
+pi: 3.14159265358979323846
+...
+`,
+			},
+		},
+		"toml": {
+			in:  "in.jsonnet",
+			out: "out.toml",
+			jy: JY{
+				J: `[{ maxInt64: 9223372036854775807 - 1024, huge: 1e20, tenth: 0.1 }]`,
+				Y: `huge = 1e+20
+maxInt64 = 9223372036854774784
+tenth = 0.10000000000000001
+`,
+			},
+		},
+		"ini": {
+			in:  "in.json",
+			out: "out.ini",
+			jy: JY{
+				J: `{"main": {"id": 12345678901234567890123, "ratio": 0.5}}`,
+				Y: `[main]
+id = 12345678901234567890123
+ratio = 0.5
+`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			log := new(bytes.Buffer)
+			p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+			tc.jy.WriteJ(t, fs, tc.in)
+			p.Process(tc.in, tc.out)
+			p.Close()
+
+			if log.Len() > 0 {
+				t.Fatalf("expected no log output, got %q", log.String())
+			}
+			tc.jy.ExpectY(t, fs, tc.out)
+		})
+	}
+}
+
+func TestProcessor_Numbers_InexactTOML(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	// Too large for a TOML integer, and not exactly a double either.
+	JY{J: `{"id": 9223372036854775809}`}.WriteJ(t, fs, "in.json")
+	p.Process("in.json", "out.toml")
+	p.Close()
+
+	want := "integer 9223372036854775809 at id cannot be represented exactly in TOML"
+	if !strings.Contains(log.String(), want) {
+		t.Fatalf("expected log to contain %q, got %q", want, log.String())
+	}
+}
+
+func TestProcessor_Numbers_TooLargeForYAML(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	// YAML readers would read this as infinity.
+	JY{J: `{"big": 1E400}`}.WriteJ(t, fs, "in.json")
+	p.Process("in.json", "out.yml")
+	p.Close()
+
+	want := "failed to write output file out.yml: document 0 at /big: number 1E400 is too large to be read from YAML"
+	if !strings.Contains(log.String(), want) {
+		t.Fatalf("expected log to contain %q, got %q", want, log.String())
+	}
+	if _, err := fs.Stat("out.yml"); err == nil {
+		t.Fatal("expected out.yml not to be written")
+	}
+}
+
+func TestProcessor_Numbers_YAML11Lint(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAML11Lint = jty.YAML11LintFail
+
+	// Numbers that a YAML 1.1 parser reads as the same value are not reported,
+	// even when they are too large for it to read as integers.
+	JY{J: `{"a": 18446744073709551615, "b": -9223372036854775808, "c": 12345678901234567890123, "d": 0.10000000000000001, "e": 1.5e-07}`}.WriteJ(t, fs, "clean.json")
+	p.Process("clean.json", "clean.yml")
+	p.Close()
+
+	if log.Len() > 0 {
+		t.Fatalf("expected no log output, got %q", log.String())
+	}
+}
+
+func TestProcessor_Numbers_YAML11Lint_Exponents(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAML11Lint = jty.YAML11LintWarn
+
+	// YAML 1.1 floats need a "." and a signed exponent; otherwise they are strings.
+	JY{J: `{"a": 1e3, "b": 1.5e7, "c": 1E+3, "d": 2.5E-3}`}.WriteJ(t, fs, "in.json")
+	p.Process("in.json", "out.yml")
+	p.Close()
+
+	want := `warning: YAML 1.1 parsers would read out.yml differently:
+  document 0 at /a: number 1e3 would be read by YAML 1.1 as string "1e3"
+  document 0 at /b: number 1.5e7 would be read by YAML 1.1 as string "1.5e7"
+  document 0 at /c: number 1E+3 would be read by YAML 1.1 as string "1E+3"
+`
+	if got := log.String(); got != want {
+		t.Fatalf("expected log:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -1,11 +1,17 @@
 package jty
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"text/template"
+	"time"
 
 	jsonnet "github.com/google/go-jsonnet"
 	"github.com/spf13/afero"
@@ -19,37 +25,203 @@ import (
 // Then the evaluated Jsonnet fans out to another set of goroutines
 // which converts the individual Jsonnet results to YAML
 // and writes the YAML to disk.
+// Plain JSON and YAML inputs are parsed in the reading goroutines
+// and sent directly to the writing goroutines, skipping evaluation.
+
+// Job is a request to compile the Jsonnet at InPath to a file saved at OutPath.
+type Job struct {
+	InPath, OutPath string
+
+	// If not empty, Code is evaluated as the Jsonnet source instead of reading InPath,
+	// and InPath is only used as the filename in error messages and as the base for relative imports.
+	Code string
+
+	// External variables and top-level arguments bound only while evaluating this job,
+	// either as plain strings or as Jsonnet code.
+	ExtVars, ExtCode map[string]string
+	TLAVars, TLACode map[string]string
+
+	// The output format; one of the Format constants.
+	// Empty means the format implied by the extension of OutPath, which defaults to YAML.
+	Format string
+
+	// The permissions of the output file, overriding Processor.Mode and Processor.KeepMode.
+	// Zero means unspecified.
+	Mode os.FileMode
+}
+
+// hasBindings reports whether j binds any external variables or top-level arguments.
+func (j Job) hasBindings() bool {
+	return len(j.ExtVars)+len(j.ExtCode)+len(j.TLAVars)+len(j.TLACode) > 0
+}
 
 // processRequest is a request to compile the jsonnet at inPath
 // to a YAML file saved at outPath.
 type processRequest struct {
-	InPath, OutPath string
+	Job
+
+	// When Process was called, for reporting the duration of the whole request.
+	Start time.Time
+
+	// Where the outcome of the request is recorded, or nil if p.RecordResults is not set.
+	Result *jobResult
 }
 
 // evalRequest is a request to evaluate the jsonnetContent
 // and store it as YAML saved at outPath.
 // inPath is only used as a string to identify the source file.
 type evalRequest struct {
-	InPath, OutPath string
+	Job
+	Start  time.Time
+	Result *jobResult
 
 	JsonnetContent string
 }
 
 // writeRequest is a request to convert the slice of JSON-encoded values
 // to YAML, saved as OutPath.
+// InPath is only used as a string to identify the source file.
 type writeRequest struct {
-	OutPath string
+	Job
+	Start  time.Time
+	Result *jobResult
 
 	Jsons []string
 }
 
+const (
+	// StdoutPath is the output path that refers to the Processor's Stdout rather than a file.
+	StdoutPath = "-"
+
+	// StdinPath is the input path that refers to the Command's Stdin rather than a file.
+	StdinPath = "-"
+)
+
 // Processor handles concurrent requests to process input Jsonnet files and save their output as YAML.
+// Input files with a .json, .yaml, or .yml extension are parsed as plain data instead of evaluated,
+// so that they are reformatted in the same style as evaluated Jsonnet.
 type Processor struct {
 	// If not nil, Processor will operate in dry run mode and write messages here.
 	// Must be set before any calls to Process.
 	DryRunDest io.Writer
 	dryRunMu   sync.Mutex
 
+	// If true, Processor logs a line for each successfully written output file,
+	// including the time taken since the call to Process and the number of bytes written.
+	// Must be set before any calls to Process.
+	Verbose bool
+
+	// If true, Processor begins each output file with GeneratedMarker,
+	// so that Prune can recognize files it generated.
+	// Must be set before any calls to Process.
+	Mark bool
+
+	// If not nil, Header is executed with a HeaderData value for each output file,
+	// and the result is written as a YAML comment ahead of the first document.
+	// Must be set before any calls to Process.
+	Header *template.Template
+
+	// If not nil, every document is validated against the applicable schemas before it is written.
+	// Must be set before any calls to Process.
+	Schemas *Schemas
+
+	// If true, Kubernetes List objects are expanded into their items,
+	// and the documents in each output are sorted in a stable order:
+	// Namespaces first, then CustomResourceDefinitions, then everything else by kind, namespace, and name.
+	// Must be set before any calls to Process.
+	KubeSort bool
+
+	// If true, Kubernetes List objects are expanded into their items,
+	// and each output path is treated as a directory
+	// in which every object is written to its own file named {namespace}/{kind}-{name}.yaml.
+	// Must be set before any calls to Process.
+	KubeSplit bool
+
+	// If set to one of the YAML11Lint constants, each YAML output is parsed again with YAML 1.1 semantics,
+	// and any value read differently from the JSON that Jsonnet produced is reported as a warning or failure.
+	// Must be set before any calls to Process.
+	YAML11Lint string
+
+	// If true, object fields named "#", "#NAME", or "//NAME" are written as YAML comments instead of data:
+	// a comment before the object, before field NAME, or at the end of field NAME's line.
+	// Outputs in other formats omit them.
+	// Must be set before any calls to Process.
+	YAMLComments bool
+
+	// If not nil, the formatting of each Jsonnet input file is checked or fixed before it is evaluated.
+	// Must be set before any calls to Process.
+	JsonnetFormatter *JsonnetFormatter
+
+	// If true, each Jsonnet input is linted after it evaluates successfully,
+	// along with each Jsonnet file it imports that no earlier input imported.
+	// Findings at or above LintFailOn are logged as errors, and others as warnings;
+	// either way, the output is still written.
+	// Must be set before any calls to Process.
+	Lint bool
+
+	// The lowest severity of lint finding that fails the run; one of the LintSeverity constants.
+	// Empty means LintSeverityError.
+	// Must be set before any calls to Process.
+	LintFailOn string
+
+	// The permissions of output files, regardless of the umask.
+	// Zero means those given by the file system when creating a file.
+	// Must be set before any calls to Process.
+	Mode os.FileMode
+
+	// If true, an output file that already exists keeps its permissions instead of taking Mode.
+	// Must be set before any calls to Process.
+	KeepMode bool
+
+	// Input paths, and the paths where imports were found, that have been linted.
+	// Only accessed from the evaluate goroutine.
+	linted map[string]bool
+
+	// If true, the outcome and duration of each job are recorded, to be reported by WriteJUnit.
+	// Must be set before any calls to Process.
+	RecordResults bool
+
+	resultsMu sync.Mutex
+	results   []*jobResult
+
+	// The result of the job being evaluated, so that errors from importers can be attributed to it.
+	// Only accessed from the evaluate goroutine.
+	evaluating *jobResult
+
+	// Destination for outputs whose path is StdoutPath.
+	// Output for each pair is written in one piece.
+	// Must be set before any calls to Process.
+	Stdout   io.Writer
+	stdoutMu sync.Mutex
+
+	// If true, outputs written to Stdout are labelled with a comment naming their source:
+	// before each YAML document, or before the whole output in other formats.
+	// The YAML documents of every pair form a single stream, terminated by Close.
+	// Set it when several pairs write to stdout.
+	// Must be set before any calls to Process.
+	LabelStdout bool
+
+	// Whether a labelled YAML document has been written to Stdout, so the stream needs terminating.
+	// Guarded by stdoutMu.
+	stdoutYAML bool
+
+	// NewVM creates a VM for evaluating jobs that bind external variables or top-level arguments,
+	// because bindings cannot be removed from a VM once they are set.
+	// One VM is created for each distinct set of bound names,
+	// and reused with new values for every job binding those names,
+	// so that imports are only parsed once, and only evaluated again when an external variable changes.
+	// If nil, jsonnet.MakeVM is used.
+	// Must be set before any calls to Process.
+	NewVM func() *jsonnet.VM
+
+	// VMs for jobs with bindings, keyed by the JSON encoding of the bound names.
+	// Only accessed from the evaluate goroutine.
+	boundVMs map[string]*boundVM
+
+	// Canonical paths of every output requested through Process, for Prune.
+	outputsMu sync.Mutex
+	outputs   map[string]struct{}
+
 	vm *jsonnet.VM
 	fs afero.Fs
 
@@ -82,6 +254,10 @@ func NewProcessor(vm *jsonnet.VM, ioWorkers int, fs afero.Fs, logDest io.Writer)
 		evalCh:  make(chan evalRequest),
 		writeCh: make(chan writeRequest, ioWorkers),
 
+		outputs:  make(map[string]struct{}),
+		boundVMs: make(map[string]*boundVM),
+		linted:   make(map[string]bool),
+
 		logDest: logDest,
 	}
 
@@ -108,12 +284,45 @@ func (p *Processor) Close() {
 
 	close(p.writeCh)
 	p.writeWG.Wait()
+
+	if p.stdoutYAML {
+		if _, err := io.WriteString(p.Stdout, "...\n"); err != nil {
+			p.log(fmt.Errorf("error writing YAML stream terminator to stdout: %v", err))
+		}
+	}
 }
 
 // Process enqueues a request to compile the jsonnet at inPath
 // and write the resulting YAML to outPath.
 func (p *Processor) Process(inPath, outPath string) {
-	p.reqCh <- processRequest{InPath: inPath, OutPath: outPath}
+	p.ProcessJob(Job{InPath: inPath, OutPath: outPath})
+}
+
+// ProcessJob enqueues j.
+func (p *Processor) ProcessJob(j Job) {
+	p.recordOutput(j.OutPath)
+
+	req := processRequest{Job: j, Start: time.Now()}
+	if p.RecordResults {
+		req.Result = &jobResult{Job: j, start: req.Start}
+
+		p.resultsMu.Lock()
+		p.results = append(p.results, req.Result)
+		p.resultsMu.Unlock()
+	}
+	p.reqCh <- req
+}
+
+// recordOutput notes that path is produced by this run, so that Prune will not remove it.
+func (p *Processor) recordOutput(path string) {
+	if path == StdoutPath {
+		return
+	}
+
+	p.outputsMu.Lock()
+	defer p.outputsMu.Unlock()
+
+	p.outputs[canonicalPath(p.fs, path)] = struct{}{}
 }
 
 func (p *Processor) readFiles() {
@@ -124,18 +333,52 @@ func (p *Processor) readFiles() {
 			p.dryRunMu.Lock()
 			_, _ = fmt.Fprintf(p.DryRunDest, "would process %s and save YAML output to %s\n", req.InPath, req.OutPath)
 			p.dryRunMu.Unlock()
+			req.Result.finish()
 			continue
 		}
-		content, err := afero.ReadFile(p.fs, req.InPath)
-		if err != nil {
-			p.log(fmt.Errorf("failed to read %s: %v", req.InPath, err))
-			continue
+		content := req.Code
+		if content == "" {
+			b, err := afero.ReadFile(p.fs, req.InPath)
+			if err != nil {
+				p.fail(req.Result, fmt.Errorf("failed to read %s: %v", req.InPath, err))
+				req.Result.finish()
+				continue
+			}
+			content = string(b)
+
+			if isDataInput(req.InPath) {
+				jsons, err := dataToJSONs(req.InPath, b)
+				if err != nil {
+					p.fail(req.Result, fmt.Errorf("failed to parse %s: %v", req.InPath, err))
+					req.Result.finish()
+					continue
+				}
+				p.writeCh <- writeRequest{
+					Job:    req.Job,
+					Start:  req.Start,
+					Result: req.Result,
+
+					Jsons: jsons,
+				}
+				continue
+			}
+
+			if p.JsonnetFormatter != nil {
+				fixed, err := p.JsonnetFormatter.handle(p.fs, req.InPath, content)
+				if err != nil {
+					// Still evaluate the input, so that its output stays up to date.
+					p.fail(req.Result, err)
+				} else if fixed && p.Verbose {
+					p.logf("reformatted %s", req.InPath)
+				}
+			}
 		}
 		p.evalCh <- evalRequest{
-			InPath:  req.InPath,
-			OutPath: req.OutPath,
+			Job:    req.Job,
+			Start:  req.Start,
+			Result: req.Result,
 
-			JsonnetContent: string(content),
+			JsonnetContent: content,
 		}
 	}
 }
@@ -144,70 +387,361 @@ func (p *Processor) evaluate() {
 	defer p.evalWG.Done()
 
 	for req := range p.evalCh {
-		jsons, err := p.vm.EvaluateSnippetStream(req.InPath, req.JsonnetContent)
+		p.evaluating = req.Result
+		vm := p.vmFor(req.Job)
+		jsons, err := vm.EvaluateSnippetStream(req.InPath, req.JsonnetContent)
 		if err != nil {
-			p.log(fmt.Errorf("failed to evaluate jsonnet at %s: %v", req.InPath, err))
+			p.fail(req.Result, fmt.Errorf("failed to evaluate jsonnet at %s: %v", req.InPath, err))
+			req.Result.finish()
 			continue
 		}
 
+		// Lint each file only once, even if several jobs evaluate it with different bindings.
+		if p.Lint && (req.Code != "" || !p.linted[req.InPath]) {
+			if req.Code == "" {
+				p.linted[req.InPath] = true
+			}
+			p.lint(vm, req)
+		}
+
 		p.writeCh <- writeRequest{
-			OutPath: req.OutPath,
+			Job:    req.Job,
+			Start:  req.Start,
+			Result: req.Result,
 
 			Jsons: jsons,
 		}
 	}
 }
 
+// boundVM is a VM for jobs that bind the same names,
+// with the external variables it currently binds.
+type boundVM struct {
+	vm               *jsonnet.VM
+	extVars, extCode map[string]string
+}
+
+// vmFor returns the VM to evaluate j with, with j's bindings set.
+// Must only be called from the evaluate goroutine.
+func (p *Processor) vmFor(j Job) *jsonnet.VM {
+	if !j.hasBindings() {
+		return p.vm
+	}
+
+	key, err := json.Marshal([][]string{sortedKeys(j.ExtVars), sortedKeys(j.ExtCode), sortedKeys(j.TLAVars), sortedKeys(j.TLACode)})
+	if err != nil {
+		// Can't happen: slices of strings always marshal.
+		panic(err)
+	}
+	b, ok := p.boundVMs[string(key)]
+	if !ok {
+		b = &boundVM{extVars: make(map[string]string), extCode: make(map[string]string)}
+		if p.NewVM != nil {
+			b.vm = p.NewVM()
+		} else {
+			b.vm = jsonnet.MakeVM()
+		}
+		p.boundVMs[string(key)] = b
+	}
+
+	// Setting an external variable discards the values of evaluated imports, so only set changed ones.
+	for k, v := range j.ExtVars {
+		if cur, ok := b.extVars[k]; !ok || cur != v {
+			b.vm.ExtVar(k, v)
+			b.extVars[k] = v
+		}
+	}
+	for k, v := range j.ExtCode {
+		if cur, ok := b.extCode[k]; !ok || cur != v {
+			b.vm.ExtCode(k, v)
+			b.extCode[k] = v
+		}
+	}
+	// Top-level arguments don't affect imports, so setting them costs nothing.
+	for k, v := range j.TLAVars {
+		b.vm.TLAVar(k, v)
+	}
+	for k, v := range j.TLACode {
+		b.vm.TLACode(k, v)
+	}
+
+	return b.vm
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (p *Processor) writeFiles() {
 	defer p.writeWG.Done()
 
 	for req := range p.writeCh {
-		if err := p.writeFile(req); err != nil {
-			p.log(fmt.Errorf("failed to write output file %s: %v", req.OutPath, err))
+		out, err := p.prepareOutput(req)
+		if err != nil {
+			p.fail(req.Result, fmt.Errorf("failed to write output file %s: %v", req.OutPath, err))
+			req.Result.finish()
+			continue
+		}
+
+		// Validate exactly the documents that will be written.
+		if p.Schemas != nil {
+			if err := p.Schemas.validate(req.OutPath, out.data); err != nil {
+				p.fail(req.Result, fmt.Errorf("failed to validate output for %s: %v", req.OutPath, err))
+				req.Result.finish()
+				continue
+			}
+		}
+
+		n, err := p.writeFile(req, out)
+		if err != nil {
+			p.fail(req.Result, fmt.Errorf("failed to write output file %s: %v", req.OutPath, err))
+			req.Result.finish()
 			continue
 		}
+		req.Result.finish()
+
+		if p.Verbose {
+			p.logf("wrote %s from %s (%d bytes) in %v", req.OutPath, req.InPath, n, time.Since(req.Start))
+		}
 	}
 }
 
-func (p *Processor) writeFile(req writeRequest) error {
-	outF, err := p.fs.Create(req.OutPath)
+// preparedOutput is the documents to write for a writeRequest, and how to encode them.
+type preparedOutput struct {
+	format string
+	encode encodeFunc
+
+	// The documents to encode, and the same documents as data, without any comment fields.
+	docs, data []interface{}
+}
+
+// prepareOutput decodes the documents of req and arranges them as they will be written.
+func (p *Processor) prepareOutput(req writeRequest) (preparedOutput, error) {
+	out := preparedOutput{format: outputFormat(req)}
+
+	var err error
+	out.encode, err = encoderFor(out.format)
+	if err != nil {
+		return out, err
+	}
+
+	out.docs, err = decodeJSONs(req.OutPath, req.Jsons)
+	if err != nil {
+		return out, err
+	}
+
+	if p.KubeSort || p.KubeSplit {
+		out.docs, err = expandKubeLists(out.docs, p.YAMLComments)
+		if err != nil {
+			return out, err
+		}
+	}
+	if p.KubeSort {
+		sortKube(out.docs)
+	}
+
+	out.data = out.docs
+	if p.YAMLComments {
+		out.data, err = stripYAMLCommentsAll(out.docs)
+		if err != nil {
+			return out, err
+		}
+		if out.format == FormatYAML {
+			out.encode = encodeCommentedYAML
+		} else {
+			out.docs = out.data
+		}
+	}
+
+	// Check before encoding, so that no partial output is written.
+	if out.format == FormatYAML {
+		if err := checkYAMLNumbers(out.data); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+// writeFile writes the output for req and returns the number of bytes written.
+func (p *Processor) writeFile(req writeRequest, out preparedOutput) (int64, error) {
+	format, encode, docs := out.format, out.encode, out.docs
+
+	if p.YAML11Lint != "" && format == FormatYAML {
+		if err := p.checkYAML11(req, out); err != nil {
+			return 0, err
+		}
+	}
+
+	if req.OutPath == StdoutPath {
+		if p.KubeSplit {
+			return 0, fmt.Errorf("cannot split Kubernetes objects into separate files on stdout")
+		}
+		return p.writeStdout(req, docs, format, encode)
+	}
+
+	if p.KubeSplit {
+		if format != FormatYAML {
+			return 0, fmt.Errorf("cannot split Kubernetes objects into %s files", strings.ToUpper(format))
+		}
+		return p.writeKubeSplit(req, docs, encode)
+	}
+
+	return p.writeEncoded(req, docs, encode)
+}
+
+// checkYAML11 reports the values in out that YAML 1.1 parsers would read differently,
+// as a warning or an error depending on p.YAML11Lint.
+func (p *Processor) checkYAML11(req writeRequest, out preparedOutput) error {
+	problems, err := lintYAML11(req.OutPath, out.docs, out.data, out.encode)
 	if err != nil {
 		return err
 	}
-	defer outF.Close()
+	if len(problems) == 0 {
+		return nil
+	}
+
+	msg := strings.Join(problems, "\n  ")
+	if p.YAML11Lint == YAML11LintFail {
+		return fmt.Errorf("YAML 1.1 parsers would read the output differently:\n  %s", msg)
+	}
+	p.logf("warning: YAML 1.1 parsers would read %s differently:\n  %s", req.OutPath, msg)
+	return nil
+}
+
+// writeStdout writes docs to p.Stdout.
+// If p.LabelStdout is set, each YAML document is labelled with the input path it came from
+// and written without a stream terminator, or the whole output is labelled for other formats.
+// The whole output is written at once so that concurrent writers don't interleave.
+func (p *Processor) writeStdout(req writeRequest, docs []interface{}, format string, encode encodeFunc) (int64, error) {
+	if p.Stdout == nil {
+		return 0, errors.New("no destination for stdout output")
+	}
+
+	var buf bytes.Buffer
+	if p.Header != nil {
+		if err := writeHeader(&buf, p.Header, req); err != nil {
+			return 0, fmt.Errorf("error writing header when writing %s: %v", req.OutPath, err)
+		}
+	}
+	labelYAML := p.LabelStdout && format == FormatYAML
+	switch {
+	case labelYAML:
+		for i, doc := range docs {
+			if _, err := fmt.Fprintf(&buf, "# Source: %s\n", req.InPath); err != nil {
+				return 0, err
+			}
+			var docBuf bytes.Buffer
+			if err := encode(&docBuf, req.OutPath, []interface{}{doc}); err != nil {
+				return 0, fmt.Errorf("document %d: %v", i, err)
+			}
+			// Close terminates the stream once, after every pair's documents.
+			buf.Write(bytes.TrimSuffix(docBuf.Bytes(), []byte("...\n")))
+		}
+	case p.LabelStdout:
+		if _, err := fmt.Fprintf(&buf, "# Source: %s\n", req.InPath); err != nil {
+			return 0, err
+		}
+		fallthrough
+	default:
+		if err := encode(&buf, req.OutPath, docs); err != nil {
+			return 0, err
+		}
+	}
+
+	p.stdoutMu.Lock()
+	defer p.stdoutMu.Unlock()
+	if labelYAML && len(docs) > 0 {
+		p.stdoutYAML = true
+	}
+	return buf.WriteTo(p.Stdout)
+}
 
-	enc := yaml.NewEncoder(outF)
+// decodeJSONs unmarshals each of the JSON-encoded jsons, keeping numbers exact as jsonNumber.
+// name is only used to identify the destination in error messages.
+func decodeJSONs(name string, jsons []string) ([]interface{}, error) {
+	docs := make([]interface{}, len(jsons))
+	for i, j := range jsons {
+		doc, err := decodeJSON(j)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling JSON object %d when writing %s: %v", i, name, err)
+		}
+		docs[i] = doc
+	}
+	return docs, nil
+}
 
-	for i, j := range req.Jsons {
-		var obj interface{}
-		if err := json.Unmarshal([]byte(j), &obj); err != nil {
-			return fmt.Errorf("error unmarshaling JSON object %d when writing %s: %v", i, req.OutPath, err)
+// writeEncoded writes docs to req.OutPath using encode and returns the number of bytes written.
+// The generated marker and header are written first as comments,
+// which every supported format writes with a leading "#".
+// The whole output is encoded before the output file is created,
+// so that a failing header template or encoding doesn't leave the file truncated.
+func (p *Processor) writeEncoded(req writeRequest, docs []interface{}, encode encodeFunc) (int64, error) {
+	var buf bytes.Buffer
+	if p.Mark {
+		buf.WriteString(GeneratedMarker + "\n")
+	}
+	if p.Header != nil {
+		if err := writeHeader(&buf, p.Header, req); err != nil {
+			return 0, fmt.Errorf("error writing header when writing %s: %v", req.OutPath, err)
 		}
+	}
+	if err := encode(&buf, req.OutPath, docs); err != nil {
+		return 0, err
+	}
+
+	f, err := p.createOutput(req)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return buf.WriteTo(f)
+}
 
+// encodeYAML writes docs to w as a YAML stream.
+// name is only used to identify the destination in error messages.
+func encodeYAML(w io.Writer, name string, docs []interface{}) error {
+	enc := yaml.NewEncoder(w)
+
+	for i, obj := range docs {
 		if i == 0 {
 			// Emit a document separator line, because the encoder doesn't do so for the first document.
-			if _, err := io.WriteString(outF, "---\n"); err != nil {
-				return fmt.Errorf("error writing first document separator when writing %s: %v", req.OutPath, err)
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return fmt.Errorf("error writing first document separator when writing %s: %v", name, err)
 			}
 		}
 		if err := enc.Encode(obj); err != nil {
-			return fmt.Errorf("error encoding YAML document %d when writing %s: %v", i, req.OutPath, err)
+			return fmt.Errorf("error encoding YAML document %d when writing %s: %v", i, name, err)
 		}
 	}
 
 	// Must have completely decoded.
 	if err := enc.Close(); err != nil {
-		return fmt.Errorf("error closing YAML encoder when writing %s: %v", req.OutPath, err)
+		return fmt.Errorf("error closing YAML encoder when writing %s: %v", name, err)
 	}
 
 	// Closing the encoder doesn't emit a stream terminator, so do that ourselves.
-	if _, err := io.WriteString(outF, "...\n"); err != nil {
-		return fmt.Errorf("error writing YAML stream terminator when writing %s: %v", req.OutPath, err)
+	if _, err := io.WriteString(w, "...\n"); err != nil {
+		return fmt.Errorf("error writing YAML stream terminator when writing %s: %v", name, err)
 	}
 
 	return nil
 }
 
+// Failed reports whether the Processor logged any error.
+// It must only be called after Close.
+func (p *Processor) Failed() bool {
+	// Don't need to take lock, as Close has finished all goroutines which may access the field.
+	return p.didLogError
+}
+
 func (p *Processor) log(err error) {
 	p.logMu.Lock()
 	defer p.logMu.Unlock()
@@ -215,3 +749,20 @@ func (p *Processor) log(err error) {
 	_, _ = fmt.Fprintln(p.logDest, err.Error())
 	p.didLogError = true
 }
+
+// fail logs err and, if r is not nil, records it as a failure of r's job.
+func (p *Processor) fail(r *jobResult, err error) {
+	p.log(err)
+	if r != nil {
+		r.failures = append(r.failures, err.Error())
+	}
+}
+
+// logf writes an informational message to the log destination.
+// Unlike log, it does not cause the Processor to be considered failed.
+func (p *Processor) logf(format string, args ...interface{}) {
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
+
+	_, _ = fmt.Fprintf(p.logDest, format+"\n", args...)
+}
@@ -2,6 +2,7 @@ package jty_test
 
 import (
 	"bytes"
+	"fmt"
 	"runtime"
 	"strings"
 	"testing"
@@ -54,3 +55,101 @@ func TestProcessor_Process(t *testing.T) {
 		t.Errorf("expected empty log, got %q", got)
 	}
 }
+
+func TestProcessor_Verbose(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.Verbose = true
+
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+	JYSeq.WriteJ(t, fs, "in2.jsonnet")
+
+	p.Process("in1.jsonnet", "out1.yml")
+	p.Process("in2.jsonnet", "out2.yml")
+	p.Close()
+
+	JYOneTwo.ExpectY(t, fs, "out1.yml")
+	JYSeq.ExpectY(t, fs, "out2.yml")
+
+	out := log.String()
+	want1 := fmt.Sprintf("wrote out1.yml from in1.jsonnet (%d bytes) in ", len(JYOneTwo.Y))
+	if !strings.Contains(out, want1) {
+		t.Errorf("expected log %q to contain %q but it didn't", out, want1)
+	}
+	want2 := fmt.Sprintf("wrote out2.yml from in2.jsonnet (%d bytes) in ", len(JYSeq.Y))
+	if !strings.Contains(out, want2) {
+		t.Errorf("expected log %q to contain %q but it didn't", out, want2)
+	}
+	if n := strings.Count(out, "\n"); n != 2 {
+		t.Errorf("expected exactly 2 log lines, got %d: %q", n, out)
+	}
+}
+
+func TestProcessor_ProcessJob_Bindings(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	JY{J: `[std.extVar('env')]`}.WriteJ(t, fs, "env.jsonnet")
+
+	p.ProcessJob(jty.Job{InPath: "env.jsonnet", OutPath: "dev.yml", ExtVars: map[string]string{"env": "dev"}})
+	p.ProcessJob(jty.Job{InPath: "env.jsonnet", OutPath: "code.yml", ExtCode: map[string]string{"env": "{a: 1}"}})
+	// Bindings must not leak into jobs without them.
+	p.ProcessJob(jty.Job{InPath: "env.jsonnet", OutPath: "none.yml"})
+	p.Close()
+
+	JY{Y: "---\ndev\n...\n"}.ExpectY(t, fs, "dev.yml")
+	JY{Y: "---\na: 1\n...\n"}.ExpectY(t, fs, "code.yml")
+
+	if _, err := fs.Stat("none.yml"); err == nil {
+		t.Error("expected none.yml not to be written")
+	}
+	want := "failed to evaluate jsonnet at env.jsonnet: "
+	if got := log.String(); !strings.Contains(got, want) {
+		t.Errorf("expected log %q to contain %q but it didn't", got, want)
+	}
+}
+
+func TestProcessor_Stdout(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	stdout := new(bytes.Buffer)
+	p.Stdout = stdout
+	p.LabelStdout = true
+
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+	JYSeq.WriteJ(t, fs, "in2.jsonnet")
+
+	p.Process("in1.jsonnet", jty.StdoutPath)
+	p.Process("in2.jsonnet", jty.StdoutPath)
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Errorf("expected empty log, got %q", got)
+	}
+
+	// The order of the two pairs depends on scheduling, but each pair's output must be contiguous,
+	// and the documents form a single stream.
+	want1 := `# Source: in1.jsonnet
+---
+one: 1
+# Source: in1.jsonnet
+---
+one: 1
+two: 2
+`
+	want2 := `# Source: in2.jsonnet
+---
+- 1
+- 2
+- 3
+- 4
+- 5
+`
+	if got := stdout.String(); got != want1+want2+"...\n" && got != want2+want1+"...\n" {
+		t.Errorf("unexpected stdout %q", got)
+	}
+}
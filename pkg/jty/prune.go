@@ -0,0 +1,82 @@
+package jty
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// GeneratedMarker is the first line of every output file written by a Processor with Mark set.
+// It follows the convention recognized by many tools for identifying generated files.
+const GeneratedMarker = "# Code generated by jty. DO NOT EDIT."
+
+// Prune removes every file under dir that begins with GeneratedMarker
+// but that was not the output of any call to Process.
+// Files without the marker are never removed.
+//
+// Prune must only be called after Close.
+// In dry run mode, Prune reports the files it would remove to DryRunDest instead of removing them.
+// Output directories of KubeSplit are skipped in dry run mode,
+// because which files they would hold isn't known without evaluating anything.
+func (p *Processor) Prune(dir string) error {
+	return afero.Walk(p.fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		_, isOutput := p.outputs[canonicalPath(p.fs, path)]
+		if info.IsDir() && isOutput && p.KubeSplit && p.DryRunDest != nil {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() || isOutput {
+			return nil
+		}
+
+		generated, err := hasGeneratedMarker(p.fs, path)
+		if err != nil {
+			return fmt.Errorf("failed to check %s for generated marker: %v", path, err)
+		}
+		if !generated {
+			return nil
+		}
+
+		if p.DryRunDest != nil {
+			p.dryRunMu.Lock()
+			_, _ = fmt.Fprintf(p.DryRunDest, "would prune stale generated file %s\n", path)
+			p.dryRunMu.Unlock()
+			return nil
+		}
+
+		if err := p.fs.Remove(path); err != nil {
+			return fmt.Errorf("failed to prune %s: %v", path, err)
+		}
+		if p.Verbose {
+			p.logf("pruned stale generated file %s", path)
+		}
+		return nil
+	})
+}
+
+// hasGeneratedMarker reports whether the file at path begins with a GeneratedMarker line.
+func hasGeneratedMarker(fs afero.Fs, path string) (bool, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	want := []byte(GeneratedMarker + "\n")
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(f, got); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// Too short to have the marker.
+			return false, nil
+		}
+		return false, err
+	}
+
+	return bytes.Equal(got, want), nil
+}
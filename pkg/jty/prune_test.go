@@ -0,0 +1,129 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func TestProcessor_Prune(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.Mark = true
+
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+
+	stale := jty.GeneratedMarker + "\n---\nstale: true\n...\n"
+	if err := afero.WriteFile(fs, "gen/stale.yml", []byte(stale), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := afero.WriteFile(fs, "gen/handwritten.yml", []byte("---\nhand: true\n...\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Process("in1.jsonnet", "gen/out1.yml")
+	p.Close()
+
+	if err := p.Prune("gen"); err != nil {
+		t.Fatal(err)
+	}
+
+	JY{Y: jty.GeneratedMarker + "\n" + JYOneTwo.Y}.ExpectY(t, fs, "gen/out1.yml")
+
+	if _, err := fs.Stat("gen/stale.yml"); err == nil {
+		t.Error("expected gen/stale.yml to be pruned")
+	}
+	if _, err := fs.Stat("gen/handwritten.yml"); err != nil {
+		t.Errorf("expected gen/handwritten.yml to be kept: %v", err)
+	}
+
+	if got := log.String(); got != "" {
+		t.Errorf("expected empty log, got %q", got)
+	}
+}
+
+func TestProcessor_Prune_DryRun(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.Mark = true
+
+	dryRunOut := new(bytes.Buffer)
+	p.DryRunDest = dryRunOut
+
+	stale := jty.GeneratedMarker + "\n---\nstale: true\n...\n"
+	if err := afero.WriteFile(fs, "gen/stale.yml", []byte(stale), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Process("in1.jsonnet", "gen/out1.yml")
+	p.Close()
+
+	if err := p.Prune("gen"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat("gen/stale.yml"); err != nil {
+		t.Errorf("expected gen/stale.yml to be kept in dry run: %v", err)
+	}
+
+	want := "would prune stale generated file gen/stale.yml\n"
+	if out := dryRunOut.String(); !strings.Contains(out, want) {
+		t.Errorf("expected output %q to contain %q but it didn't", out, want)
+	}
+}
+
+func TestCommand_Prune_DryRun_KubeSplit(t *testing.T) {
+	tc := NewTestCommand("")
+	JY{J: kubeJsonnet}.WriteJ(t, tc.FS, "app.jsonnet")
+
+	if err := tc.Cmd.Run(&jty.Flags{Args: []string{"app.jsonnet", "out/app"}, KubeSplit: true, Prune: []string{"out"}, Mark: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := jty.GeneratedMarker + "\n---\nstale: true\n...\n"
+	if err := afero.WriteFile(tc.FS, "out/stale.yml", []byte(stale), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tc.Stdout.Reset()
+	if err := tc.Cmd.Run(&jty.Flags{Args: []string{"app.jsonnet", "out/app"}, KubeSplit: true, Prune: []string{"out"}, Mark: true, DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := tc.Stdout.String()
+	if want := "would prune stale generated file out/stale.yml\n"; !strings.Contains(out, want) {
+		t.Errorf("expected output %q to contain %q but it didn't", out, want)
+	}
+	if strings.Contains(out, "out/app/") {
+		t.Errorf("expected no split output to be pruned, got %q", out)
+	}
+}
+
+func TestCommand_Prune_SkippedOnError(t *testing.T) {
+	tc := NewTestCommand("")
+
+	stale := jty.GeneratedMarker + "\n---\nstale: true\n...\n"
+	if err := afero.WriteFile(tc.FS, "gen/stale.yml", []byte(stale), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.Cmd.Run(&jty.Flags{
+		// Input file that doesn't exist.
+		Args:  []string{"in1.jsonnet", "gen/out1.yml"},
+		Mark:  true,
+		Prune: []string{"gen"},
+	}); err != jty.ErrEncounteredErrors {
+		t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+	}
+
+	if _, err := tc.FS.Stat("gen/stale.yml"); err != nil {
+		t.Errorf("expected gen/stale.yml to be kept after failed run: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package jty
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+)
+
+// sandboxImporter refuses to import any file that resolves outside its allowed roots,
+// so that untrusted Jsonnet can't read arbitrary files through import or importstr.
+type sandboxImporter struct {
+	jsonnet.Importer
+
+	// Canonical root directories.
+	roots []string
+}
+
+// newSandboxImporter returns an importer that only imports files within roots,
+// after resolving symlinks.
+func newSandboxImporter(imp jsonnet.Importer, roots []string) *sandboxImporter {
+	canonical := make([]string, len(roots))
+	for i, r := range roots {
+		// The wrapped importer always reads from the OS filesystem.
+		canonical[i] = canonicalPath(osFs, r)
+	}
+
+	return &sandboxImporter{Importer: imp, roots: canonical}
+}
+
+// importRoots returns the directories imports may resolve within:
+// the given roots and every library search path.
+func importRoots(roots, jpaths []string) []string {
+	all := make([]string, 0, len(roots)+len(jpaths))
+	all = append(all, roots...)
+	return append(all, jpaths...)
+}
+
+func (i *sandboxImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	contents, foundAt, err := i.Importer.Import(importedFrom, importedPath)
+	if err != nil {
+		return contents, foundAt, err
+	}
+
+	if p := canonicalPath(osFs, foundAt); !i.allowed(p) {
+		return jsonnet.Contents{}, "", fmt.Errorf("refusing to import %s: it resolves to %s, outside the allowed import roots", importedPath, p)
+	}
+
+	return contents, foundAt, nil
+}
+
+// allowed reports whether the canonical path p is within any of the roots.
+func (i *sandboxImporter) allowed(p string) bool {
+	return withinRoots(p, i.roots)
+}
+
+// withinRoots reports whether the canonical path p is within any of the canonical directories in roots.
+func withinRoots(p string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
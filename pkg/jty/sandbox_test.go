@@ -0,0 +1,149 @@
+package jty_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+// setupSandbox creates a temporary directory containing:
+//
+//	root/ok.txt
+//	root/escape -> ../outside/secret.txt
+//	lib/lib.txt
+//	outside/secret.txt
+func setupSandbox(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "jty-sandbox-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []string{"root", "lib", "outside"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		"root/ok.txt":        "ok",
+		"lib/lib.txt":        "lib",
+		"outside/secret.txt": "secret",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join("..", "outside", "secret.txt"), filepath.Join(dir, "root", "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestCommand_ImportRoot(t *testing.T) {
+	dir := setupSandbox(t)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	lib := filepath.Join(dir, "lib")
+
+	for name, tc := range map[string]struct {
+		code    string
+		wantErr string
+	}{
+		"within root":     {code: `[importstr '` + filepath.Join(root, "ok.txt") + `']`},
+		"library path":    {code: `[importstr 'lib.txt']`},
+		"outside root":    {code: `[importstr '` + filepath.Join(dir, "outside", "secret.txt") + `']`, wantErr: "outside the allowed import roots"},
+		"dot-dot":         {code: `[importstr '` + root + `/../outside/secret.txt']`, wantErr: "outside the allowed import roots"},
+		"through symlink": {code: `[importstr '` + filepath.Join(root, "escape") + `']`, wantErr: "outside the allowed import roots"},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			cmd := NewTestCommand("")
+			err := cmd.Cmd.Run(&jty.Flags{
+				Args:        []string{tc.code, "out.yml"},
+				Exec:        true,
+				ImportRoots: []string{root},
+				JPaths:      []string{lib},
+			})
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v: %s", err, cmd.Stderr.String())
+				}
+				return
+			}
+
+			if err != jty.ErrEncounteredErrors {
+				t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+			}
+			if got := cmd.Stderr.String(); !strings.Contains(got, "failed to evaluate jsonnet") || !strings.Contains(got, tc.wantErr) {
+				t.Fatalf("expected evaluation error containing %q, got %q", tc.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestCommand_NoImportRoot(t *testing.T) {
+	dir := setupSandbox(t)
+	defer os.RemoveAll(dir)
+
+	cmd := NewTestCommand("")
+	if err := cmd.Cmd.Run(&jty.Flags{
+		Args: []string{`[importstr '` + filepath.Join(dir, "root", "escape") + `']`, "out.yml"},
+		Exec: true,
+	}); err != nil {
+		t.Fatalf("unexpected error %v: %s", err, cmd.Stderr.String())
+	}
+
+	JY{Y: "---\nsecret\n...\n"}.ExpectY(t, cmd.FS, "out.yml")
+}
+
+func TestCommand_ImportRoot_Natives(t *testing.T) {
+	dir := setupSandbox(t)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+
+	for name, tc := range map[string]struct {
+		path    string
+		wantErr string
+	}{
+		"within root":     {path: filepath.Join(root, "ok.txt")},
+		"outside root":    {path: filepath.Join(dir, "outside", "secret.txt"), wantErr: "outside the allowed import roots"},
+		"through symlink": {path: filepath.Join(root, "escape"), wantErr: "outside the allowed import roots"},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			cmd := NewTestCommand("")
+			cmd.Cmd.FS = afero.NewOsFs()
+			out := filepath.Join(dir, strings.Replace(name, " ", "-", -1)+".yml")
+			err := cmd.Cmd.Run(&jty.Flags{
+				Args:        []string{`[std.native('sha256File')('` + tc.path + `')]`, out},
+				Exec:        true,
+				Natives:     true,
+				ImportRoots: []string{root},
+			})
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v: %s", err, cmd.Stderr.String())
+				}
+				return
+			}
+
+			if err != jty.ErrEncounteredErrors {
+				t.Fatalf("expected ErrEncounteredErrors, got %v", err)
+			}
+			if got := cmd.Stderr.String(); !strings.Contains(got, tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %q", tc.wantErr, got)
+			}
+		})
+	}
+}
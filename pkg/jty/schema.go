@@ -0,0 +1,206 @@
+package jty
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema"
+	"github.com/spf13/afero"
+)
+
+// Schemas selects the JSON Schemas that output documents must satisfy.
+// A schema can apply to every document in output files matching a glob,
+// or to every Kubernetes object with a particular apiVersion and kind.
+//
+// A Schemas value must be fully populated before it is assigned to a Processor.
+type Schemas struct {
+	fs       afero.Fs
+	compiler *jsonschema.Compiler
+
+	// Compiled schemas, keyed by path.
+	compiled map[string]*jsonschema.Schema
+
+	byGlob []globSchema
+
+	// Keyed by apiVersion + "/" + kind.
+	byKind map[string][]namedSchema
+}
+
+type namedSchema struct {
+	path   string
+	schema *jsonschema.Schema
+}
+
+type globSchema struct {
+	glob string
+	namedSchema
+}
+
+// NewSchemas returns an empty Schemas that loads schema files from fs.
+func NewSchemas(fs afero.Fs) *Schemas {
+	return &Schemas{
+		fs:       fs,
+		compiler: jsonschema.NewCompiler(),
+		compiled: make(map[string]*jsonschema.Schema),
+		byKind:   make(map[string][]namedSchema),
+	}
+}
+
+// AddGlob validates every document written to an output path matching glob
+// against the schema at schemaPath.
+// If glob contains no path separator, it is matched against the base name of the output path.
+func (s *Schemas) AddGlob(glob, schemaPath string) error {
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid glob %q: %v", glob, err)
+	}
+
+	schema, err := s.load(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	s.byGlob = append(s.byGlob, globSchema{glob: glob, namedSchema: namedSchema{path: schemaPath, schema: schema}})
+	return nil
+}
+
+// AddKind validates every document with the given apiVersion and kind
+// against the schema at schemaPath.
+// apiVersionKind is formatted like "apps/v1/Deployment" or "v1/ConfigMap".
+func (s *Schemas) AddKind(apiVersionKind, schemaPath string) error {
+	i := strings.LastIndexByte(apiVersionKind, '/')
+	if i <= 0 || i == len(apiVersionKind)-1 {
+		return fmt.Errorf("invalid apiVersion/kind %q: must be formatted like apps/v1/Deployment", apiVersionKind)
+	}
+
+	schema, err := s.load(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	s.byKind[apiVersionKind] = append(s.byKind[apiVersionKind], namedSchema{path: schemaPath, schema: schema})
+	return nil
+}
+
+func (s *Schemas) load(path string) (*jsonschema.Schema, error) {
+	if schema, ok := s.compiled[path]; ok {
+		return schema, nil
+	}
+
+	f, err := s.fs.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schema: %v", err)
+	}
+	defer f.Close()
+
+	if err := s.compiler.AddResource(path, f); err != nil {
+		return nil, fmt.Errorf("failed to load schema %s: %v", path, err)
+	}
+	schema, err := s.compiler.Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema %s: %v", path, err)
+	}
+
+	s.compiled[path] = schema
+	return schema, nil
+}
+
+// validate checks each of docs, the decoded documents destined for outPath,
+// against every applicable schema.
+func (s *Schemas) validate(outPath string, docs []interface{}) error {
+	var globSchemas []namedSchema
+	cleanOut := filepath.Clean(outPath)
+	for _, gs := range s.byGlob {
+		target := cleanOut
+		if !strings.ContainsRune(gs.glob, filepath.Separator) {
+			target = filepath.Base(cleanOut)
+		}
+		if ok, _ := filepath.Match(gs.glob, target); ok {
+			globSchemas = append(globSchemas, gs.namedSchema)
+		}
+	}
+
+	var violations []string
+	for i, doc := range docs {
+		doc := schemaValue(doc)
+
+		schemas := globSchemas
+		if key := kindKey(doc); key != "" {
+			schemas = append(schemas[:len(schemas):len(schemas)], s.byKind[key]...)
+		}
+
+		for _, ns := range schemas {
+			err := ns.schema.ValidateInterface(doc)
+			if err == nil {
+				continue
+			}
+			ve, ok := err.(*jsonschema.ValidationError)
+			if !ok {
+				return fmt.Errorf("error validating document %d against schema %s: %v", i, ns.path, err)
+			}
+			for _, leaf := range leafViolations(ve) {
+				violations = append(violations, fmt.Sprintf("document %d violates schema %s at %s: %s", i, ns.path, leaf.InstancePtr, leaf.Message))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		sort.Strings(violations)
+		return fmt.Errorf("schema validation failed:\n  %s", strings.Join(violations, "\n  "))
+	}
+	return nil
+}
+
+// schemaValue returns a copy of v with numbers as json.Number, as the schema validator requires.
+func schemaValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case jsonNumber:
+		return json.Number(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = schemaValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = schemaValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// kindKey returns the apiVersion/kind of doc, if it looks like a Kubernetes object.
+func kindKey(doc interface{}) string {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	apiVersion, _ := m["apiVersion"].(string)
+	kind, _ := m["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return ""
+	}
+
+	return apiVersion + "/" + kind
+}
+
+// leafViolations returns the most specific causes of ve,
+// which are the ones that point at the offending part of the document.
+func leafViolations(ve *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(ve.Causes) == 0 {
+		return []*jsonschema.ValidationError{ve}
+	}
+
+	var leaves []*jsonschema.ValidationError
+	for _, c := range ve.Causes {
+		leaves = append(leaves, leafViolations(c)...)
+	}
+	return leaves
+}
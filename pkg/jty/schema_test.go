@@ -0,0 +1,166 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+const replicasSchema = `{
+  "type": "object",
+  "properties": {
+    "spec": {
+      "type": "object",
+      "properties": {"replicas": {"type": "integer", "minimum": 1}}
+    }
+  }
+}`
+
+func TestProcessor_Schemas_Glob(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	if err := afero.WriteFile(fs, "schema.json", []byte(replicasSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := jty.NewSchemas(fs)
+	if err := s.AddGlob("deploy-*.yml", "schema.json"); err != nil {
+		t.Fatal(err)
+	}
+	p.Schemas = s
+
+	JY{J: `[{spec: {replicas: 2}}]`}.WriteJ(t, fs, "good.jsonnet")
+	JY{J: `[{spec: {replicas: 2}}, {spec: {replicas: 'two'}}]`}.WriteJ(t, fs, "bad.jsonnet")
+
+	p.Process("good.jsonnet", "out/deploy-good.yml")
+	p.Process("bad.jsonnet", "out/deploy-bad.yml")
+	// Not matched by the glob, so not validated.
+	p.Process("bad.jsonnet", "out/other.yml")
+	p.Close()
+
+	if _, err := fs.Stat("out/deploy-good.yml"); err != nil {
+		t.Errorf("expected deploy-good.yml to be written: %v", err)
+	}
+	if _, err := fs.Stat("out/other.yml"); err != nil {
+		t.Errorf("expected other.yml to be written: %v", err)
+	}
+	if _, err := fs.Stat("out/deploy-bad.yml"); err == nil {
+		t.Error("expected deploy-bad.yml not to be written")
+	}
+
+	out := log.String()
+	want := "failed to validate output for out/deploy-bad.yml: schema validation failed:\n  document 1 violates schema schema.json at #/spec/replicas: "
+	if !strings.Contains(out, want) {
+		t.Errorf("expected log %q to contain %q but it didn't", out, want)
+	}
+	if strings.Count(out, "violates schema") != 1 {
+		t.Errorf("expected exactly one violation in log, got %q", out)
+	}
+}
+
+func TestProcessor_Schemas_Kind(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+
+	if err := afero.WriteFile(fs, "schema.json", []byte(replicasSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := jty.NewSchemas(fs)
+	if err := s.AddKind("apps/v1/Deployment", "schema.json"); err != nil {
+		t.Fatal(err)
+	}
+	p.Schemas = s
+
+	JY{J: `[
+  {apiVersion: 'v1', kind: 'ConfigMap', spec: {replicas: 0}},
+  {apiVersion: 'apps/v1', kind: 'Deployment', spec: {replicas: 0}},
+]`}.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	out := log.String()
+	want := "document 1 violates schema schema.json at #/spec/replicas: "
+	if !strings.Contains(out, want) {
+		t.Errorf("expected log %q to contain %q but it didn't", out, want)
+	}
+	if strings.Contains(out, "document 0") {
+		t.Errorf("expected ConfigMap not to be validated, got %q", out)
+	}
+}
+
+func TestProcessor_Schemas_KubeList(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.KubeSort = true
+
+	if err := afero.WriteFile(fs, "schema.json", []byte(replicasSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := jty.NewSchemas(fs)
+	if err := s.AddKind("apps/v1/Deployment", "schema.json"); err != nil {
+		t.Fatal(err)
+	}
+	p.Schemas = s
+
+	// Objects inside a List are validated as the documents they are written as,
+	// and numbered by their position in the sorted output.
+	JY{J: `[{
+  apiVersion: 'v1',
+  kind: 'List',
+  items: [
+    {apiVersion: 'v1', kind: 'Service', metadata: {name: 'b'}},
+    {apiVersion: 'apps/v1', kind: 'Deployment', metadata: {name: 'a'}, spec: {replicas: 0}},
+  ],
+}]`}.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	want := "failed to validate output for out.yml: schema validation failed:\n  document 0 violates schema schema.json at #/spec/replicas: "
+	if out := log.String(); !strings.Contains(out, want) {
+		t.Errorf("expected log %q to contain %q but it didn't", out, want)
+	}
+	if exists, _ := afero.Exists(fs, "out.yml"); exists {
+		t.Error("expected invalid output not to be written")
+	}
+}
+
+func TestSchemas_Invalid(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "schema.json", []byte(replicasSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := jty.NewSchemas(fs)
+
+	if err := s.AddKind("Deployment", "schema.json"); err == nil {
+		t.Error("expected error for kind without apiVersion")
+	}
+	if err := s.AddGlob("[", "schema.json"); err == nil {
+		t.Error("expected error for malformed glob")
+	}
+	if err := s.AddGlob("*.yml", "missing.json"); err == nil {
+		t.Error("expected error for missing schema file")
+	}
+}
+
+func TestCommand_Schemas_InvalidFlag(t *testing.T) {
+	tc := NewTestCommand("")
+
+	err := tc.Cmd.Run(&jty.Flags{
+		Args:    []string{"in1.jsonnet", "out1.yml"},
+		Schemas: []string{"*.yml"},
+	})
+	want := `invalid --schema value "*.yml": must be formatted as KEY=VALUE`
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,286 @@
+package jty
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+	"time"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/spf13/afero"
+)
+
+// RenderArgs are the arguments to the Jty.Render RPC method.
+type RenderArgs struct {
+	// Path of the Jsonnet file to render.
+	// If Code is set, Path is only used as the filename in error messages
+	// and as the base for relative imports.
+	Path string
+
+	// Jsonnet source to render instead of reading Path.
+	Code string
+}
+
+// RenderReply is the result of the Jty.Render RPC method.
+// Exactly one of YAML and Error is set.
+type RenderReply struct {
+	YAML  string       `json:",omitempty"`
+	Error *RenderError `json:",omitempty"`
+}
+
+// RenderError describes why a render request failed.
+type RenderError struct {
+	// The stage that failed: "read", "evaluate", or "encode".
+	Stage string
+
+	Message string
+}
+
+// Server renders Jsonnet to YAML on request,
+// keeping a single VM alive so that imported files are only read once
+// until they change on disk.
+type Server struct {
+	fs afero.Fs
+
+	newImporter func() jsonnet.Importer
+
+	mu       sync.Mutex
+	vm       *jsonnet.VM
+	importer *recordingImporter
+}
+
+// NewServer returns a Server that evaluates Jsonnet in vm and reads the rendered files from fs.
+// newImporter is called to create the VM's importer initially,
+// and again to discard cached imports whenever an imported file changes.
+func NewServer(vm *jsonnet.VM, newImporter func() jsonnet.Importer, fs afero.Fs) *Server {
+	s := &Server{
+		fs:          fs,
+		newImporter: newImporter,
+		vm:          vm,
+	}
+	s.resetImporter()
+	return s
+}
+
+func (s *Server) resetImporter() {
+	s.importer = &recordingImporter{
+		Importer:    s.newImporter(),
+		newImporter: s.newImporter,
+		modTimes:    make(map[string]time.Time),
+		lookups:     make(map[importLookup]string),
+	}
+	s.vm.Importer(s.importer)
+}
+
+// Render renders the Jsonnet described by args.
+// Failures are reported in reply.Error rather than as an error,
+// so that clients get structured information about what went wrong.
+func (s *Server) Render(args RenderArgs) RenderReply {
+	code := args.Code
+	if code == "" {
+		content, err := afero.ReadFile(s.fs, args.Path)
+		if err != nil {
+			return RenderReply{Error: &RenderError{Stage: "read", Message: err.Error()}}
+		}
+		code = string(content)
+	}
+
+	s.mu.Lock()
+	if s.importer.stale() {
+		s.resetImporter()
+	}
+	jsons, err := s.vm.EvaluateSnippetStream(args.Path, code)
+	s.mu.Unlock()
+	if err != nil {
+		return RenderReply{Error: &RenderError{Stage: "evaluate", Message: err.Error()}}
+	}
+
+	docs, err := decodeJSONs(args.Path, jsons)
+	if err != nil {
+		return RenderReply{Error: &RenderError{Stage: "encode", Message: err.Error()}}
+	}
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, args.Path, docs); err != nil {
+		return RenderReply{Error: &RenderError{Stage: "encode", Message: err.Error()}}
+	}
+
+	return RenderReply{YAML: buf.String()}
+}
+
+// renderService exposes a Server's methods over net/rpc.
+type renderService struct {
+	s *Server
+}
+
+// Render is the Jty.Render RPC method.
+func (r renderService) Render(args RenderArgs, reply *RenderReply) error {
+	*reply = r.s.Render(args)
+	return nil
+}
+
+func (s *Server) rpcServer() *rpc.Server {
+	rs := rpc.NewServer()
+	if err := rs.RegisterName("Jty", renderService{s: s}); err != nil {
+		// Can't happen: renderService always has a suitable method.
+		panic(err)
+	}
+	return rs
+}
+
+// ServeConn serves JSON-RPC 1.0 requests on conn until the client hangs up.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	s.rpcServer().ServeCodec(jsonrpc.NewServerCodec(conn))
+}
+
+// Serve accepts connections on l and serves JSON-RPC 1.0 requests on each of them.
+// It returns when l.Accept fails.
+func (s *Server) Serve(l net.Listener) error {
+	rs := s.rpcServer()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go rs.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// recordingImporter remembers the modification time of each file it imports,
+// and where each import was resolved, including imports that weren't found,
+// so that it can tell when its cached contents are stale.
+type recordingImporter struct {
+	jsonnet.Importer
+
+	newImporter func() jsonnet.Importer
+
+	mu       sync.Mutex
+	modTimes map[string]time.Time
+	lookups  map[importLookup]string
+}
+
+// importLookup is an import as written, before it is resolved to a file.
+type importLookup struct {
+	importedFrom, importedPath string
+}
+
+func (i *recordingImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	contents, foundAt, err := i.Importer.Import(importedFrom, importedPath)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	// The underlying importer caches failed lookups too, so remember them,
+	// to notice when the missing file is created.
+	i.lookups[importLookup{importedFrom, importedPath}] = foundAt
+	if err != nil {
+		return contents, foundAt, err
+	}
+	if _, ok := i.modTimes[foundAt]; !ok {
+		i.modTimes[foundAt] = modTimeOf(foundAt, contents.String())
+	}
+
+	return contents, foundAt, nil
+}
+
+// modTimeOf returns the modification time of the file at path, which was read as contents.
+// The file is stat'd before it is read again, so that a change after the stat is always noticed later;
+// if the file no longer has the imported contents, or can't be stat'd or read,
+// it gets the zero time, and is considered stale on the next check.
+func modTimeOf(path, contents string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil || string(b) != contents {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// stale reports whether any imported file has been modified or removed since it was imported,
+// or whether any import would now resolve differently,
+// because a missing file was created or a file earlier in the search path shadows the one found.
+func (i *recordingImporter) stale() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for path, modTime := range i.modTimes {
+		fi, err := os.Stat(path)
+		if err != nil || !fi.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+
+	fresh := i.newImporter()
+	for l, foundAt := range i.lookups {
+		if _, now, _ := fresh.Import(l.importedFrom, l.importedPath); now != foundAt {
+			return true
+		}
+	}
+	return false
+}
+
+// stdio combines a reader and a writer into an io.ReadWriteCloser for ServeConn.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdio) Close() error { return nil }
+
+// Serve runs a Server until stdin is closed, or forever if f.Socket is set.
+func (c *Command) Serve(f *ServeFlags) error {
+	var roots []string
+	if len(f.ImportRoots) > 0 {
+		roots = importRoots(f.ImportRoots, f.JPaths)
+	}
+	newImporter := func() jsonnet.Importer {
+		imp := &jsonnet.FileImporter{JPaths: f.JPaths}
+		if roots != nil {
+			return newSandboxImporter(imp, roots)
+		}
+		return imp
+	}
+	vm := jsonnet.MakeVM()
+	for _, nf := range c.natives(f.Natives, roots) {
+		vm.NativeFunction(nf)
+	}
+	s := NewServer(vm, newImporter, c.FS)
+
+	if f.Socket == "" {
+		s.ServeConn(stdio{Reader: c.Stdin, Writer: c.Stdout})
+		return nil
+	}
+
+	l, err := listenUnix(f.Socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", f.Socket, err)
+	}
+	defer l.Close()
+
+	return s.Serve(l)
+}
+
+// listenUnix listens on the Unix socket at path,
+// first removing a socket left behind by a server that didn't shut down cleanly.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("file exists and is not a socket")
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another server is listening")
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
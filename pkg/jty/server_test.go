@@ -0,0 +1,216 @@
+package jty_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+func newTestServer(fs afero.Fs, jpaths ...string) *jty.Server {
+	return jty.NewServer(jsonnet.MakeVM(), func() jsonnet.Importer {
+		return &jsonnet.FileImporter{JPaths: jpaths}
+	}, fs)
+}
+
+func TestServer_Render(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+	s := newTestServer(fs)
+
+	reply := s.Render(jty.RenderArgs{Path: "in1.jsonnet"})
+	if reply.Error != nil {
+		t.Fatalf("unexpected error: %+v", reply.Error)
+	}
+	if reply.YAML != JYOneTwo.Y {
+		t.Fatalf("expected YAML %q, got %q", JYOneTwo.Y, reply.YAML)
+	}
+
+	reply = s.Render(jty.RenderArgs{Path: "virtual.jsonnet", Code: JYSeq.J})
+	if reply.Error != nil {
+		t.Fatalf("unexpected error: %+v", reply.Error)
+	}
+	if reply.YAML != JYSeq.Y {
+		t.Fatalf("expected YAML %q, got %q", JYSeq.Y, reply.YAML)
+	}
+}
+
+func TestServer_Render_Errors(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := newTestServer(fs)
+
+	reply := s.Render(jty.RenderArgs{Path: "missing.jsonnet"})
+	if reply.Error == nil || reply.Error.Stage != "read" {
+		t.Fatalf("expected read error, got %+v", reply)
+	}
+
+	reply = s.Render(jty.RenderArgs{Path: "bad.jsonnet", Code: `[error 'boom']`})
+	if reply.Error == nil || reply.Error.Stage != "evaluate" || !strings.Contains(reply.Error.Message, "boom") {
+		t.Fatalf("expected evaluate error mentioning boom, got %+v", reply)
+	}
+}
+
+func TestServer_Render_InvalidatesChangedImports(t *testing.T) {
+	libdir, err := ioutil.TempDir("", "jty-serve-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(libdir)
+
+	lib := filepath.Join(libdir, "lib.libsonnet")
+	if err := ioutil.WriteFile(lib, []byte("{X: 1}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(afero.NewMemMapFs(), libdir)
+	args := jty.RenderArgs{Path: "in.jsonnet", Code: `[import 'lib.libsonnet']`}
+
+	if reply := s.Render(args); reply.YAML != "---\nX: 1\n...\n" {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+
+	if err := ioutil.WriteFile(lib, []byte("{X: 2}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Ensure the modification time differs even on filesystems with coarse timestamps.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(lib, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply := s.Render(args); reply.YAML != "---\nX: 2\n...\n" {
+		t.Fatalf("expected changed import to be reloaded, got %+v", reply)
+	}
+}
+
+func TestServer_Render_NoticesNewImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jty-serve-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vendor := filepath.Join(dir, "vendor")
+	if err := os.Mkdir(vendor, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(afero.NewMemMapFs(), vendor)
+	args := jty.RenderArgs{Path: filepath.Join(dir, "in.jsonnet"), Code: `[import 'lib.libsonnet']`}
+
+	if reply := s.Render(args); reply.Error == nil || !strings.Contains(reply.Error.Message, "couldn't open import") {
+		t.Fatalf("expected missing import error, got %+v", reply)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(vendor, "lib.libsonnet"), []byte("{X: 1}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reply := s.Render(args); reply.YAML != "---\nX: 1\n...\n" {
+		t.Fatalf("expected created import to be found, got %+v", reply)
+	}
+
+	// A file next to the importing file shadows the library path.
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib.libsonnet"), []byte("{X: 2}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reply := s.Render(args); reply.YAML != "---\nX: 2\n...\n" {
+		t.Fatalf("expected shadowing import to be found, got %+v", reply)
+	}
+}
+
+func TestServer_ServeConn(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	JYOneTwo.WriteJ(t, fs, "in1.jsonnet")
+	s := newTestServer(fs)
+
+	serverConn, clientConn := net.Pipe()
+	go s.ServeConn(serverConn)
+
+	client := jsonrpc.NewClient(clientConn)
+	defer client.Close()
+
+	var reply jty.RenderReply
+	if err := client.Call("Jty.Render", jty.RenderArgs{Path: "in1.jsonnet"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.YAML != JYOneTwo.Y {
+		t.Fatalf("expected YAML %q, got %+v", JYOneTwo.Y, reply)
+	}
+}
+
+func TestCommand_Serve_StaleSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jty-serve-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Leave a socket behind, as a server that was killed would.
+	sock := filepath.Join(dir, "jty.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	tc := NewTestCommand("")
+	errs := make(chan error, 1)
+	go func() { errs <- tc.Cmd.Serve(&jty.ServeFlags{Socket: sock}) }()
+
+	var conn net.Conn
+	for deadline := time.Now().Add(5 * time.Second); conn == nil; {
+		select {
+		case err := <-errs:
+			t.Fatalf("server exited: %v", err)
+		default:
+		}
+		if conn, err = net.Dial("unix", sock); err != nil {
+			if time.Now().After(deadline) {
+				t.Fatalf("failed to connect: %v", err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	client := jsonrpc.NewClient(conn)
+	defer client.Close()
+
+	var reply jty.RenderReply
+	if err := client.Call("Jty.Render", jty.RenderArgs{Path: "in.jsonnet", Code: JYOneTwo.J}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.YAML != JYOneTwo.Y {
+		t.Fatalf("expected YAML %q, got %+v", JYOneTwo.Y, reply)
+	}
+}
+
+func TestCommand_Serve_SocketNotASocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jty-serve-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "jty.sock")
+	if err := ioutil.WriteFile(path, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := NewTestCommand("")
+	err = tc.Cmd.Serve(&jty.ServeFlags{Socket: path})
+	if want := "failed to listen on " + path + ": file exists and is not a socket"; err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "keep" {
+		t.Fatalf("expected file to be left alone, got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,226 @@
+package jty
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+	"github.com/spf13/afero"
+)
+
+// ConflictError is returned when the given input-output pairs conflict with one another,
+// such that the result of processing them would depend on scheduling.
+type ConflictError struct {
+	Conflicts []string
+}
+
+func (e *ConflictError) Error() string {
+	return "conflicting input-output pairs:\n  " + strings.Join(e.Conflicts, "\n  ")
+}
+
+// checkConflicts returns a *ConflictError if any two pairs write the same output path,
+// or if any pair writes to a path that is the input of any pair.
+// Paths are compared after cleaning and resolving symlinks.
+// Any number of pairs may write to StdoutPath.
+func checkConflicts(fs afero.Fs, reqs []Job) error {
+	var conflicts []string
+
+	// Map of canonical path to 1-based index of the first pair using it.
+	inputs := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		if req.Code != "" {
+			// Not a file.
+			continue
+		}
+		in := canonicalPath(fs, req.InPath)
+		if _, ok := inputs[in]; !ok {
+			inputs[in] = i + 1
+		}
+	}
+
+	outputs := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		if req.OutPath == StdoutPath {
+			// Output to stdout is serialized, so any number of pairs may use it.
+			continue
+		}
+
+		n := i + 1
+		out := canonicalPath(fs, req.OutPath)
+
+		if prev, ok := outputs[out]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("output %s of pair %d is also the output of pair %d", req.OutPath, n, prev))
+		} else {
+			outputs[out] = n
+		}
+
+		if in, ok := inputs[out]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("output %s of pair %d is the input of pair %d", req.OutPath, n, in))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// checkImportedOutputs returns a *ConflictError if any Jsonnet input imports the output of any pair,
+// directly or through other imports, because its result would depend on whether that pair had been written yet.
+// Jsonnet import paths are always string literals, so parsing finds every import without evaluating anything.
+// Inputs and imports that can't be read or parsed are skipped, since evaluating them reports the error.
+func checkImportedOutputs(fs afero.Fs, imp jsonnet.Importer, reqs []Job) error {
+	// Map of canonical output path to 1-based index of the pair writing it.
+	outputs := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		if req.OutPath != StdoutPath {
+			outputs[canonicalPath(fs, req.OutPath)] = i + 1
+		}
+	}
+
+	var conflicts []string
+	parsed := make(map[string]bool)
+	for i, req := range reqs {
+		code := req.Code
+		if code == "" {
+			if isDataInput(req.InPath) {
+				continue
+			}
+			b, err := afero.ReadFile(fs, req.InPath)
+			if err != nil {
+				continue
+			}
+			code = string(b)
+		}
+
+		// Imported files are on the OS filesystem, and the importer caches them for evaluation.
+		var walk func(path, code string)
+		walk = func(path, code string) {
+			node, err := jsonnet.SnippetToAST(path, code)
+			if err != nil {
+				return
+			}
+			for _, imported := range importedPaths(node) {
+				contents, foundAt, err := imp.Import(path, imported.path)
+				if err != nil {
+					continue
+				}
+				if out, ok := outputs[canonicalPath(osFs, foundAt)]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("input %s of pair %d imports %s, the output of pair %d", req.InPath, i+1, foundAt, out))
+					continue
+				}
+				if imported.parse && !parsed[foundAt] {
+					parsed[foundAt] = true
+					walk(foundAt, contents.String())
+				}
+			}
+		}
+		walk(req.InPath, code)
+	}
+
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// importPath is a path given to import or importstr.
+type importPath struct {
+	path string
+
+	// Whether the imported file is Jsonnet, rather than a string from importstr.
+	parse bool
+}
+
+// importedPaths returns the paths imported anywhere in node, in order.
+func importedPaths(node ast.Node) []importPath {
+	var paths []importPath
+	var visit func(n ast.Node)
+	visit = func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.Import:
+			paths = append(paths, importPath{path: n.File.Value, parse: true})
+		case *ast.ImportStr:
+			paths = append(paths, importPath{path: n.File.Value})
+		}
+		for _, c := range toolutils.Children(n) {
+			visit(c)
+		}
+	}
+	visit(node)
+	return paths
+}
+
+// osFs is used to canonicalize paths that are known to be on the OS filesystem.
+var osFs = afero.NewOsFs()
+
+// canonicalPath returns an absolute, cleaned version of p.
+// If fs is backed by the OS filesystem, symlinks are resolved too,
+// including in the parent directories of a file that does not yet exist.
+func canonicalPath(fs afero.Fs, p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+
+	if _, ok := fs.(*afero.OsFs); !ok {
+		return abs
+	}
+	return evalSymlinks(abs)
+}
+
+// evalSymlinks resolves symlinks in the absolute path p.
+// If p does not exist, its deepest existing ancestor is resolved instead.
+func evalSymlinks(p string) string {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err == nil {
+		return resolved
+	}
+
+	dir, base := filepath.Split(p)
+	dir = filepath.Clean(dir)
+	if dir == p {
+		// Reached the root without resolving anything.
+		return p
+	}
+	return filepath.Join(evalSymlinks(dir), base)
+}
+
+// outputGuardImporter refuses to import any file that is the output of an input-output pair,
+// because its content would depend on whether that pair had been written yet.
+// checkImportedOutputs finds these imports before processing starts;
+// this is a safeguard for any it couldn't see, such as imports from an input that failed to parse.
+type outputGuardImporter struct {
+	jsonnet.Importer
+
+	// Canonical output paths.
+	outputs map[string]struct{}
+}
+
+func newOutputGuardImporter(imp jsonnet.Importer, fs afero.Fs, reqs []Job) *outputGuardImporter {
+	outputs := make(map[string]struct{}, len(reqs))
+	for _, req := range reqs {
+		if req.OutPath != StdoutPath {
+			outputs[canonicalPath(fs, req.OutPath)] = struct{}{}
+		}
+	}
+
+	return &outputGuardImporter{Importer: imp, outputs: outputs}
+}
+
+func (i *outputGuardImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	contents, foundAt, err := i.Importer.Import(importedFrom, importedPath)
+	if err != nil {
+		return contents, foundAt, err
+	}
+
+	// The wrapped importer always reads from the OS filesystem.
+	if _, ok := i.outputs[canonicalPath(osFs, foundAt)]; ok {
+		return jsonnet.Contents{}, "", fmt.Errorf("refusing to import %s: it is the output of an input-output pair", foundAt)
+	}
+
+	return contents, foundAt, nil
+}
@@ -0,0 +1,190 @@
+package jty
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml11 "gopkg.in/yaml.v2"
+)
+
+// Values for Processor.YAML11Lint.
+const (
+	// Log each YAML 1.1 ambiguity, but write the output anyway.
+	YAML11LintWarn = "warn"
+
+	// Fail the pair if its output has any YAML 1.1 ambiguity.
+	YAML11LintFail = "fail"
+)
+
+// lintYAML11 encodes docs with encode, exactly as they are written, parses the result with YAML 1.1 semantics,
+// and returns a description of every value that a YAML 1.1 parser would read
+// differently from the same document in data.
+func lintYAML11(name string, docs, data []interface{}, encode encodeFunc) ([]string, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, name, docs); err != nil {
+		return nil, err
+	}
+
+	dec := yaml11.NewDecoder(&buf)
+	var problems []string
+	for i := 0; ; i++ {
+		var got interface{}
+		if err := dec.Decode(&got); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error parsing YAML document %d as YAML 1.1: %v", i, err)
+		}
+		if i >= len(data) {
+			// The stream terminator can't produce a document, but be defensive.
+			break
+		}
+
+		for _, p := range diffYAML11("", data[i], got) {
+			problems = append(problems, fmt.Sprintf("document %d at %s", i, p))
+		}
+	}
+
+	return problems, nil
+}
+
+// diffYAML11 compares the JSON value want with got, the same value as read by a YAML 1.1 parser,
+// and describes each difference, prefixed with its JSON pointer below path.
+func diffYAML11(path string, want, got interface{}) []string {
+	ptr := path
+	if ptr == "" {
+		ptr = "/"
+	}
+
+	switch want := want.(type) {
+	case map[string]interface{}:
+		gotMap, ok := got.(map[interface{}]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: object would be read by YAML 1.1 as %s", ptr, describeYAML11(got))}
+		}
+
+		keys := make([]string, 0, len(want))
+		for k := range want {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var problems []string
+		for _, k := range keys {
+			childPath := path + "/" + escapeJSONPointer(k)
+			g, ok := gotMap[k]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: key %q would be read by YAML 1.1 as %s", childPath, k, describeYAML11Scalar(k)))
+				continue
+			}
+			problems = append(problems, diffYAML11(childPath, want[k], g)...)
+		}
+		return problems
+
+	case []interface{}:
+		gotSlice, ok := got.([]interface{})
+		if !ok || len(gotSlice) != len(want) {
+			return []string{fmt.Sprintf("%s: array would be read by YAML 1.1 as %s", ptr, describeYAML11(got))}
+		}
+
+		var problems []string
+		for i := range want {
+			problems = append(problems, diffYAML11(path+"/"+strconv.Itoa(i), want[i], gotSlice[i])...)
+		}
+		return problems
+
+	case jsonNumber:
+		if yaml11ExponentString(want) {
+			// yaml.v2 reads these as numbers, but YAML 1.1 itself doesn't.
+			got = string(want)
+		} else if yaml11NumberEquals(want, got) {
+			return nil
+		}
+	default:
+		// Strings, booleans, and null compare directly.
+		if want == got {
+			return nil
+		}
+	}
+
+	return []string{fmt.Sprintf("%s: %s would be read by YAML 1.1 as %s", ptr, describeYAML11(want), describeYAML11(got))}
+}
+
+// yaml11NumberEquals reports whether got, as produced by the YAML 1.1 parser, is the number n.
+// Integers the parser reads as integers are compared exactly.
+// Integers too large for it are read as floats, and any parser reading floats rounds them the same way,
+// so that is not reported.
+func yaml11NumberEquals(n jsonNumber, got interface{}) bool {
+	var i *big.Int
+	switch got := got.(type) {
+	case int:
+		i = big.NewInt(int64(got))
+	case int64:
+		i = big.NewInt(got)
+	case uint64:
+		i = new(big.Int).SetUint64(got)
+	case float64:
+		return n.float64() == got
+	default:
+		return false
+	}
+
+	if want := n.bigInt(); want != nil {
+		return want.Cmp(i) == 0
+	}
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return n.float64() == f
+}
+
+// yaml11Float matches the numbers with exponents that YAML 1.1 reads as floats,
+// which need a "." in the mantissa and a sign in the exponent.
+var yaml11Float = regexp.MustCompile(`^-?[0-9]+\.[0-9]*[eE][-+][0-9]+$`)
+
+// yaml11ExponentString reports whether n has an exponent, but a spec-following YAML 1.1 parser
+// would read it as a string rather than a float, as with 1e3 or 1.5e7.
+func yaml11ExponentString(n jsonNumber) bool {
+	return strings.ContainsAny(string(n), "eE") && !yaml11Float.MatchString(string(n))
+}
+
+// describeYAML11Scalar describes how a YAML 1.1 parser reads s as a plain scalar.
+func describeYAML11Scalar(s string) string {
+	var v interface{}
+	if err := yaml11.Unmarshal([]byte(s), &v); err != nil {
+		return "a different value"
+	}
+	return describeYAML11(v)
+}
+
+func describeYAML11(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case jsonNumber:
+		return "number " + string(v)
+	case int, int64, uint64:
+		return fmt.Sprintf("number %d", v)
+	case float64:
+		return "number " + formatJSONNumber(v)
+	case []interface{}:
+		return "an array"
+	case map[interface{}]interface{}, map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T %v", v, v)
+	}
+}
+
+// escapeJSONPointer escapes a reference token for use in a JSON pointer.
+func escapeJSONPointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
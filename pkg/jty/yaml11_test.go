@@ -0,0 +1,115 @@
+package jty_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+const ambiguousJsonnet = `[{
+  on: 'yes',
+  mode: '0755',
+  exp: '1e3',
+  count: 1e3,
+  list: ['y', 'n', 'ok'],
+}]`
+
+func TestProcessor_YAML11Lint_Warn(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAML11Lint = jty.YAML11LintWarn
+
+	JY{J: ambiguousJsonnet}.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	want := `warning: YAML 1.1 parsers would read out.yml differently:
+  document 0 at /list/0: string "y" would be read by YAML 1.1 as boolean true
+  document 0 at /list/1: string "n" would be read by YAML 1.1 as boolean false
+  document 0 at /on: key "on" would be read by YAML 1.1 as boolean true
+`
+	if got := log.String(); got != want {
+		t.Fatalf("expected log:\n%s\ngot:\n%s", want, got)
+	}
+
+	// The output is still written.
+	if _, err := fs.Stat("out.yml"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessor_YAML11Lint_Fail(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAML11Lint = jty.YAML11LintFail
+
+	JY{J: ambiguousJsonnet}.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	if got := log.String(); !strings.Contains(got, "failed to write output file out.yml: YAML 1.1 parsers would read the output differently") {
+		t.Fatalf("expected failure, got %q", got)
+	}
+	if _, err := fs.Stat("out.yml"); err == nil {
+		t.Fatal("expected out.yml not to be written")
+	}
+}
+
+func TestProcessor_YAML11Lint_Clean(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAML11Lint = jty.YAML11LintFail
+
+	JYOneTwo.WriteJ(t, fs, "in.jsonnet")
+	JY{J: `[{s: 'plain', none: null, b: false, f: 0.25, big: 1e21, a: [{}]}]`}.WriteJ(t, fs, "in2.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Process("in2.jsonnet", "out2.yml")
+	p.Close()
+
+	if got := log.String(); got != "" {
+		t.Fatalf("expected empty log, got %q", got)
+	}
+	JYOneTwo.ExpectY(t, fs, "out.yml")
+}
+
+func TestProcessor_YAML11Lint_Comments(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	log := new(bytes.Buffer)
+	p := jty.NewProcessor(jsonnet.MakeVM(), runtime.GOMAXPROCS(-1), fs, log)
+	p.YAML11Lint = jty.YAML11LintWarn
+	p.YAMLComments = true
+
+	// The commented output is linted as it is written, against the data without comment fields.
+	JY{J: `[{'#': 'app', a: 'y', '//a': 'flag', x: {'#': 'empty'}, l: [{'#': 'item'}]}]`}.WriteJ(t, fs, "in.jsonnet")
+
+	p.Process("in.jsonnet", "out.yml")
+	p.Close()
+
+	want := `warning: YAML 1.1 parsers would read out.yml differently:
+  document 0 at /a: string "y" would be read by YAML 1.1 as boolean true
+`
+	if got := log.String(); got != want {
+		t.Fatalf("expected log:\n%s\ngot:\n%s", want, got)
+	}
+	JY{Y: "---\n# app\na: y # flag\nl:\n  # item\n  - {}\nx: {\n    # empty\n}\n...\n"}.ExpectY(t, fs, "out.yml")
+}
+
+func TestCommand_InvalidYAML11Lint(t *testing.T) {
+	tc := NewTestCommand("")
+
+	err := tc.Cmd.Run(&jty.Flags{Args: []string{"in.jsonnet", "out.yml"}, YAML11Lint: "error"})
+	if err == nil || !strings.Contains(err.Error(), `invalid --yaml11-lint "error"`) {
+		t.Fatalf("expected invalid --yaml11-lint error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package jtytest
+
+import (
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Diff returns a line-by-line diff from want to got,
+// with removed lines prefixed by "-", added lines by "+", and nearby unchanged lines by " ".
+// Runs of unchanged lines far from any change are elided as "...".
+// It returns the empty string if want and got are equal.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+
+	a := splitLines(want)
+	b := splitLines(got)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	// Keep unchanged lines only if they are within diffContext lines of a change.
+	keep := make([]bool, len(lines))
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for c := k - diffContext; c <= k+diffContext; c++ {
+			if c >= 0 && c < len(lines) {
+				keep[c] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	elided := false
+	for k, l := range lines {
+		if !keep[k] {
+			if !elided {
+				sb.WriteString("...\n")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		sb.WriteByte(l.op)
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// splitLines splits s into lines, marking a missing final newline so that it shows up in diffs.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += " (no newline at end)"
+	return lines
+}
@@ -0,0 +1,43 @@
+package jtytest_test
+
+import (
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jtytest"
+)
+
+func TestDiff(t *testing.T) {
+	for name, tc := range map[string]struct {
+		want, got, diff string
+	}{
+		"equal": {
+			want: "a\nb\n",
+			got:  "a\nb\n",
+			diff: "",
+		},
+		"changed line": {
+			want: "a\nb\nc\n",
+			got:  "a\nB\nc\n",
+			diff: " a\n-b\n+B\n c\n",
+		},
+		"added and removed": {
+			want: "a\nb\n",
+			got:  "b\nc\n",
+			diff: "-a\n b\n+c\n",
+		},
+		"elided context": {
+			want: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			got:  "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			diff: "...\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		"missing final newline": {
+			want: "a\n",
+			got:  "a",
+			diff: "-a\n+a (no newline at end)\n",
+		},
+	} {
+		if got := jtytest.Diff(tc.want, tc.got); got != tc.diff {
+			t.Errorf("%s: expected diff %q, got %q", name, tc.diff, got)
+		}
+	}
+}
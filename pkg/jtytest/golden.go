@@ -0,0 +1,134 @@
+// Package jtytest helps test Jsonnet libraries with go test,
+// by comparing the YAML that jty produces against golden files.
+package jtytest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsonnet "github.com/google/go-jsonnet"
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/spf13/afero"
+)
+
+// UpdateEnv is the environment variable that rewrites golden files when set to a non-empty value,
+// which unlike the -jtytest.update flag can be given to go test ./... across packages that don't use jtytest.
+const UpdateEnv = "JTYTEST_UPDATE"
+
+// update is registered on the test binary of any package that imports jtytest,
+// under a name that won't collide with a package's own -update flag.
+var update = flag.Bool("jtytest.update", false, "Rewrite jtytest golden files with the current output.")
+
+// GoldenSuffix replaces the .jsonnet extension of an input to name its golden file.
+const GoldenSuffix = ".golden.yml"
+
+// Golden runs every *.jsonnet file in a directory through a jty.Processor
+// and compares each output with the file of the same name ending in GoldenSuffix.
+type Golden struct {
+	// The directory containing the .jsonnet files and their golden files.
+	// Subdirectories are not searched.
+	Dir string
+
+	// Library search paths, the same as jty's --jpath flag.
+	JPaths []string
+
+	// If set, called on each Processor before it processes a file,
+	// to set options such as KubeSort or Header.
+	Configure func(p *jty.Processor)
+
+	// If true, golden files are rewritten with the current output instead of being compared.
+	// The -jtytest.update flag and the UpdateEnv environment variable have the same effect.
+	Update bool
+}
+
+// Run processes each .jsonnet file in g.Dir in its own subtest of t,
+// failing with a diff if the output doesn't match the golden file.
+func (g Golden) Run(t *testing.T) {
+	t.Helper()
+
+	inputs, err := filepath.Glob(filepath.Join(g.Dir, "*.jsonnet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("no .jsonnet files in %s", g.Dir)
+	}
+
+	for _, in := range inputs {
+		in := in
+		t.Run(filepath.Base(in), func(t *testing.T) {
+			g.check(t, in)
+		})
+	}
+}
+
+func (g Golden) check(t *testing.T, in string) {
+	t.Helper()
+
+	got, err := g.Render(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := goldenPath(in)
+	if g.Update || *update || os.Getenv(UpdateEnv) != "" {
+		if err := ioutil.WriteFile(golden, got, 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v; run go test with -jtytest.update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output of %s does not match %s; run go test with -jtytest.update to accept it:\n%s", in, golden, Diff(string(want), string(got)))
+	}
+}
+
+func goldenPath(in string) string {
+	return strings.TrimSuffix(in, ".jsonnet") + GoldenSuffix
+}
+
+// Render returns the YAML that a Processor configured by g writes for the Jsonnet file at in.
+// The input is read from the OS filesystem, but the output is only written to memory,
+// at the path of the golden file.
+func (g Golden) Render(in string) ([]byte, error) {
+	content, err := ioutil.ReadFile(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Processor reads the input from the same path in memory,
+	// while imports are resolved on disk relative to it.
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, in, content, 0666); err != nil {
+		return nil, err
+	}
+
+	vm := jsonnet.MakeVM()
+	vm.Importer(&jsonnet.FileImporter{JPaths: g.JPaths})
+
+	var log bytes.Buffer
+	p := jty.NewProcessor(vm, 1, fs, &log)
+	if g.Configure != nil {
+		g.Configure(p)
+	}
+
+	out := goldenPath(in)
+	p.Process(in, out)
+	p.Close()
+
+	if p.Failed() {
+		return nil, fmt.Errorf("jty failed:\n%s", log.String())
+	}
+
+	return afero.ReadFile(fs, out)
+}
@@ -0,0 +1,98 @@
+package jtytest_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark-rushakoff/jty/pkg/jty"
+	"github.com/mark-rushakoff/jty/pkg/jtytest"
+)
+
+// Test packages commonly define their own -update flag,
+// which would panic when this package initializes if jtytest used the same name.
+var _ = flag.Bool("update", false, "An unrelated -update flag.")
+
+func TestGolden_Run(t *testing.T) {
+	jtytest.Golden{Dir: "testdata"}.Run(t)
+}
+
+func TestGolden_Update(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jtytest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "a.jsonnet")
+	if err := ioutil.WriteFile(in, []byte("[{a: 1}]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	g := jtytest.Golden{
+		Dir:    dir,
+		Update: true,
+		Configure: func(p *jty.Processor) {
+			p.Mark = true
+		},
+	}
+	g.Run(t)
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "a.golden.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := jty.GeneratedMarker + "\n---\na: 1\n...\n"
+	if string(got) != want {
+		t.Fatalf("expected golden file %q, got %q", want, got)
+	}
+
+	// Having been updated, the golden file now matches.
+	g.Update = false
+	g.Run(t)
+}
+
+func TestGolden_UpdateEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jtytest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.jsonnet"), []byte("[{a: 1}]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(jtytest.UpdateEnv, "1")
+	jtytest.Golden{Dir: dir}.Run(t)
+	os.Unsetenv(jtytest.UpdateEnv)
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "a.golden.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\na: 1\n...\n"; string(got) != want {
+		t.Fatalf("expected golden file %q, got %q", want, got)
+	}
+}
+
+func TestGolden_Render_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jtytest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "bad.jsonnet")
+	if err := ioutil.WriteFile(in, []byte("[error 'boom']"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = jtytest.Golden{Dir: dir}.Render(in)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected evaluation error, got %v", err)
+	}
+}